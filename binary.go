@@ -0,0 +1,630 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+)
+
+// The binary encoding written by AdvancedError#MarshalBinary() and RemoteError#MarshalBinary()
+// is more compact than the JSON one, but carries the same information: the error chain (incl. MultiErrors)
+// with redacted messages, the symbolized and filtered stack segments and the redacted fields.
+// As both types implement encoding.BinaryMarshaler, encoding/gob uses it, too.
+// RemoteError#UnmarshalBinary() decodes it. The format is:
+//
+//	encoding = version node                  ; version is 1
+//	node     = 0 string                      ; plain error message
+//	         | 1 node segments fields        ; AdvancedError or RemoteError
+//	         | 2 string node                 ; MessageError
+//	         | 3 uvarint *node               ; MultiError with that amount of errors
+//	         | 4 string uvarint *node        ; RetryError with reason and attempts
+//	         | 5 uvarint string node         ; RepeatedError with count, RFC 3339 since and last repetition
+//	         | 6 node                        ; CircuitOpenError with last failure, inside 1 if it has a stack
+//	segments = uvarint *(string uvarint *frame) ; goroutines with "created by" and frames, see StackSegment
+//	frame    = string varint string uvarint context ; file, line, function, repeated
+//	context  = 0                             ; no SourceContext
+//	         | 1 uvarint *string string uvarint *string ; pre context lines, context line, post context lines
+//	fields   = uvarint *(string string)      ; keys and JSON values
+//	string   = uvarint bytes                 ; 0, length and the bytes of a new string
+//	         | uvarint                       ; 1-based index of an already encountered new string
+//
+// All integers are encoded as by encoding/binary.PutUvarint and PutVarint.
+const binaryVersion = 1
+
+const (
+	binaryPlain byte = iota
+	binaryStack
+	binaryMessage
+	binaryMulti
+	binaryRetry
+	binaryRepeated
+	binaryCircuitOpen
+)
+
+// maxBinaryDepth limits the nesting of decoded errors.
+const maxBinaryDepth = 1000
+
+var (
+	errBinaryVersion   error = plainError("unsupported binary error encoding version")
+	errBinaryMalformed error = plainError("malformed binary error encoding")
+	errBinaryDepth     error = plainError("binary error encoding nested too deeply")
+)
+
+var _ encoding.BinaryMarshaler = AdvancedError{}
+
+// MarshalBinary encodes $ae as documented above binaryVersion.
+func (ae AdvancedError) MarshalBinary() ([]byte, error) {
+	return marshalErrorBinary(ae)
+}
+
+var _ encoding.BinaryMarshaler = RemoteError{}
+
+// MarshalBinary encodes $re like AdvancedError#MarshalBinary().
+func (re RemoteError) MarshalBinary() ([]byte, error) {
+	return marshalErrorBinary(re)
+}
+
+var _ encoding.BinaryUnmarshaler = (*RemoteError)(nil)
+
+// UnmarshalBinary decodes the encoding written by AdvancedError#MarshalBinary(). Nested errors become RemoteErrors,
+// MessageErrors, MultiErrors, RetryErrors, RepeatedErrors and CircuitOpenErrors (inside RemoteErrors with stacks).
+// Field values are decoded like by UnmarshalJSON.
+func (re *RemoteError) UnmarshalBinary(data []byte) error {
+	d := &binaryDecoder{r: bytes.NewReader(data)}
+
+	if version, err := d.r.ReadByte(); err != nil {
+		return errBinaryMalformed
+	} else if version != binaryVersion {
+		return errBinaryVersion
+	}
+
+	message, nested, err := d.node()
+	if err != nil {
+		return err
+	}
+
+	if d.r.Len() > 0 {
+		return errBinaryMalformed
+	}
+
+	*re = toRemoteError(message, nested)
+	return nil
+}
+
+// marshalErrorBinary encodes $err as documented above binaryVersion.
+func marshalErrorBinary(err error) ([]byte, error) {
+	e := &binaryEncoder{refs: map[string]uint64{}}
+	e.buf.WriteByte(binaryVersion)
+
+	if err := e.node(err); err != nil {
+		return nil, err
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// toRemoteError returns $nested if it's a RemoteError, a RemoteError with $nested or $message otherwise.
+func toRemoteError(message string, nested error) RemoteError {
+	if re, ok := nested.(RemoteError); ok {
+		return re
+	}
+
+	return RemoteError{Message: message, Err: nested}
+}
+
+// binaryEncoder writes the encoding documented above binaryVersion.
+type binaryEncoder struct {
+	buf bytes.Buffer
+	// refs maps already written strings to their 1-based indices.
+	refs    map[string]uint64
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *binaryEncoder) node(err error) error {
+	r := GetRedactor()
+
+	switch ee := err.(type) {
+	case nil:
+		e.buf.WriteByte(binaryPlain)
+		e.string("")
+	case AdvancedError:
+		err, fields := peelFields(ee.Err)
+		return e.stack(err, "", ee.renderedSegments(GetStackFilter()), fields)
+	case FieldsError:
+		return e.stack(ee.Err, "", nil, ee.Fields)
+	case RemoteError:
+		return e.stack(ee.Err, ee.Message, ee.renderedSegments(GetStackFilter()), ee.Fields)
+	case FilteredError:
+		switch inner := ee.Err.(type) {
+		case AdvancedError:
+			err, fields := peelFields(inner.Err)
+			return e.stack(err, "", ee.renderedSegments(), fields)
+		case RemoteError:
+			return e.stack(inner.Err, inner.Message, ee.renderedSegments(), inner.Fields)
+		default:
+			return e.stack(inner, "", ee.renderedSegments(), nil)
+		}
+	case MessageError:
+		e.buf.WriteByte(binaryMessage)
+		e.string(r.Redact(ee.Msg))
+		return e.node(ee.Err)
+	case MultiError:
+		e.buf.WriteByte(binaryMulti)
+		return e.attempts(ee)
+	case RetryError:
+		e.buf.WriteByte(binaryRetry)
+		e.string(r.Redact(ee.reason()))
+		return e.attempts(ee.Attempts)
+	case RepeatedError:
+		e.buf.WriteByte(binaryRepeated)
+		e.uvarint(uint64(ee.Count))
+		e.string(ee.Since.Format(time.RFC3339Nano))
+		return e.node(ee.Err)
+	case CircuitOpenError:
+		if len(ee.Stack) < 1 {
+			e.buf.WriteByte(binaryCircuitOpen)
+			return e.node(ee.LastFailure)
+		}
+
+		e.buf.WriteByte(binaryStack)
+		e.buf.WriteByte(binaryCircuitOpen)
+
+		if err := e.node(ee.LastFailure); err != nil {
+			return err
+		}
+
+		return e.trailer(AdvancedError{Stack: ee.Stack}.renderedSegments(GetStackFilter()), nil)
+	default:
+		e.buf.WriteByte(binaryPlain)
+		e.string(r.Redact(err.Error()))
+	}
+
+	return nil
+}
+
+// attempts writes the amount of $errs and each one with its stack.
+func (e *binaryEncoder) attempts(errs MultiError) error {
+	e.uvarint(uint64(len(errs)))
+
+	for _, err := range errs {
+		if !rendersOwnStack(err) {
+			err = AdvancedError{Err: err, Stack: err.StackTrace()}
+		}
+
+		if err := e.node(err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stack writes an AdvancedError or RemoteError wrapping $err, or just having $message if $err is nil.
+func (e *binaryEncoder) stack(err error, message string, segs []frameSegment, fields []Field) error {
+	e.buf.WriteByte(binaryStack)
+
+	if err == nil {
+		e.buf.WriteByte(binaryPlain)
+		e.string(GetRedactor().Redact(message))
+	} else if err := e.node(err); err != nil {
+		return err
+	}
+
+	return e.trailer(segs, fields)
+}
+
+// trailer writes the $segs and $fields of an AdvancedError or RemoteError.
+func (e *binaryEncoder) trailer(segs []frameSegment, fields []Field) error {
+	e.uvarint(uint64(len(segs)))
+	for _, seg := range segs {
+		e.string(seg.createdBy)
+		e.uvarint(uint64(len(seg.frames)))
+
+		for _, frame := range seg.frames {
+			e.frame(frame)
+		}
+	}
+
+	r := GetRedactor()
+	e.uvarint(uint64(len(fields)))
+
+	for _, field := range fields {
+		value, err := json.Marshal(r.redactField(field.Key, field.Value))
+		if err != nil {
+			return err
+		}
+
+		e.string(field.Key)
+		e.string(string(value))
+	}
+
+	return nil
+}
+
+func (e *binaryEncoder) frame(frame StackFrame) {
+	e.string(frame.File)
+	e.buf.Write(e.scratch[:binary.PutVarint(e.scratch[:], int64(frame.Line))])
+	e.string(frame.Function)
+	e.uvarint(uint64(frame.Repeated))
+
+	if frame.SourceContext == nil {
+		e.buf.WriteByte(0)
+		return
+	}
+
+	e.buf.WriteByte(1)
+	e.strings(frame.PreContext)
+	e.string(frame.ContextLine)
+	e.strings(frame.PostContext)
+}
+
+func (e *binaryEncoder) strings(s []string) {
+	e.uvarint(uint64(len(s)))
+	for _, str := range s {
+		e.string(str)
+	}
+}
+
+// string writes $s or, if it has already been written, a reference to it.
+func (e *binaryEncoder) string(s string) {
+	if i, ok := e.refs[s]; ok {
+		e.uvarint(i)
+		return
+	}
+
+	e.uvarint(0)
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+	e.refs[s] = uint64(len(e.refs)) + 1
+}
+
+func (e *binaryEncoder) uvarint(i uint64) {
+	e.buf.Write(e.scratch[:binary.PutUvarint(e.scratch[:], i)])
+}
+
+// binaryDecoder reads the encoding documented above binaryVersion.
+type binaryDecoder struct {
+	r *bytes.Reader
+	// table holds the already read strings.
+	table []string
+	// values maps indices of d.table to the strings' already decoded JSON values.
+	values map[int]interface{}
+	depth  int
+}
+
+// node decodes either a $message or a $nested error like decodeErrorJSON.
+func (d *binaryDecoder) node() (message string, nested error, err error) {
+	if d.depth >= maxBinaryDepth {
+		return "", nil, errBinaryDepth
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+
+	kind, errRB := d.r.ReadByte()
+	if errRB != nil {
+		return "", nil, errBinaryMalformed
+	}
+
+	switch kind {
+	case binaryPlain:
+		message, err = d.string()
+	case binaryStack:
+		var re RemoteError
+		re, err = d.stack()
+		nested = re
+	case binaryMessage:
+		var me MessageError
+		if me.Msg, err = d.string(); err != nil {
+			return
+		}
+
+		if me.Err, err = d.error(); err != nil {
+			return
+		}
+
+		nested = me
+	case binaryMulti:
+		nested, err = d.attempts()
+	case binaryRetry:
+		var re RetryError
+		var reason string
+		if reason, err = d.string(); err != nil {
+			return
+		}
+
+		re.Reason = retryReason(reason)
+		re.Attempts, err = d.attempts()
+		nested = re
+	case binaryRepeated:
+		var re RepeatedError
+		count, errRU := binary.ReadUvarint(d.r)
+		if errRU != nil || count > math.MaxInt32 {
+			return "", nil, errBinaryMalformed
+		}
+
+		re.Count = int(count)
+
+		var since string
+		if since, err = d.string(); err != nil {
+			return
+		}
+
+		if re.Since, err = time.Parse(time.RFC3339Nano, since); err != nil {
+			return "", nil, errBinaryMalformed
+		}
+
+		if re.Err, err = d.error(); err != nil {
+			return
+		}
+
+		nested = re
+	case binaryCircuitOpen:
+		var coe CircuitOpenError
+		var msg string
+		var inner error
+		if msg, inner, err = d.node(); err != nil {
+			return
+		}
+
+		if ws, ok := inner.(ErrorWithStack); ok {
+			coe.LastFailure = ws
+		} else {
+			coe.LastFailure = toRemoteError(msg, inner)
+		}
+
+		nested = coe
+	default:
+		err = errBinaryMalformed
+	}
+
+	return
+}
+
+// attempts decodes a MultiError.
+func (d *binaryDecoder) attempts() (MultiError, error) {
+	n, err := d.count()
+	if err != nil {
+		return nil, err
+	}
+
+	me := make(MultiError, 0, n)
+	for i := 0; i < n; i++ {
+		msg, inner, err := d.node()
+		if err != nil {
+			return nil, err
+		}
+
+		if ws, ok := inner.(ErrorWithStack); ok {
+			me = append(me, ws)
+		} else {
+			me = append(me, toRemoteError(msg, inner))
+		}
+	}
+
+	return me, nil
+}
+
+// error decodes a node as an error, a plain message as such.
+func (d *binaryDecoder) error() (error, error) {
+	msg, nested, err := d.node()
+	if err != nil || nested != nil {
+		return nested, err
+	}
+
+	return plainError(msg), nil
+}
+
+// retryReason returns the well-known RetryError#Reason with the message $reason, if any, or a new error.
+func retryReason(reason string) error {
+	if reason == "" {
+		return nil
+	}
+
+	known := []error{ErrNotRetryable, ErrMaxAttempts, ErrMaxElapsed, context.Canceled, context.DeadlineExceeded}
+	for _, known := range known {
+		if known.Error() == reason {
+			return known
+		}
+	}
+
+	return plainError(reason)
+}
+
+func (d *binaryDecoder) stack() (re RemoteError, err error) {
+	if re.Message, re.Err, err = d.node(); err != nil {
+		return
+	}
+
+	segs, err := d.count()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < segs; i++ {
+		createdBy, err := d.string()
+		if err != nil {
+			return re, err
+		}
+
+		if i > 0 {
+			re.Segments = append(re.Segments, StackSegment{len(re.Frames), createdBy})
+		}
+
+		frames, err := d.count()
+		if err != nil {
+			return re, err
+		}
+
+		for j := 0; j < frames; j++ {
+			frame, err := d.frame()
+			if err != nil {
+				return re, err
+			}
+
+			re.Frames = append(re.Frames, frame)
+		}
+	}
+
+	fields, err := d.count()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < fields; i++ {
+		key, err := d.string()
+		if err != nil {
+			return re, err
+		}
+
+		value, err := d.jsonValue()
+		if err != nil {
+			return re, err
+		}
+
+		re.Fields = append(re.Fields, Field{key, value})
+	}
+
+	return
+}
+
+func (d *binaryDecoder) frame() (frame StackFrame, err error) {
+	if frame.File, err = d.string(); err != nil {
+		return
+	}
+
+	line, errRV := binary.ReadVarint(d.r)
+	if errRV != nil {
+		return frame, errBinaryMalformed
+	}
+
+	frame.Line = int(line)
+
+	if frame.Function, err = d.string(); err != nil {
+		return
+	}
+
+	repeated, errRU := binary.ReadUvarint(d.r)
+	if errRU != nil || repeated > math.MaxInt32 {
+		return frame, errBinaryMalformed
+	}
+
+	frame.Repeated = int(repeated)
+
+	hasContext, errRB := d.r.ReadByte()
+	switch {
+	case errRB != nil || hasContext > 1:
+		return frame, errBinaryMalformed
+	case hasContext == 1:
+		sc := &SourceContext{}
+		if sc.PreContext, err = d.strings(); err != nil {
+			return
+		}
+
+		if sc.ContextLine, err = d.string(); err != nil {
+			return
+		}
+
+		if sc.PostContext, err = d.strings(); err != nil {
+			return
+		}
+
+		frame.SourceContext = sc
+	}
+
+	return
+}
+
+func (d *binaryDecoder) strings() ([]string, error) {
+	n, err := d.count()
+	if err != nil || n < 1 {
+		return nil, err
+	}
+
+	s := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		str, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+
+		s = append(s, str)
+	}
+
+	return s, nil
+}
+
+// string reads a new string or a reference to an already read one.
+func (d *binaryDecoder) string() (string, error) {
+	s, _, err := d.indexedString()
+	return s, err
+}
+
+// indexedString is like string, but also returns the 0-based index of the string in d.table.
+func (d *binaryDecoder) indexedString() (string, int, error) {
+	ref, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", 0, errBinaryMalformed
+	}
+
+	if ref > 0 {
+		if ref > uint64(len(d.table)) {
+			return "", 0, errBinaryMalformed
+		}
+
+		return d.table[ref-1], int(ref - 1), nil
+	}
+
+	length, err := binary.ReadUvarint(d.r)
+	if err != nil || length > uint64(d.r.Len()) {
+		return "", 0, errBinaryMalformed
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", 0, errBinaryMalformed
+	}
+
+	d.table = append(d.table, string(buf))
+	return string(buf), len(d.table) - 1, nil
+}
+
+// jsonValue reads a string and decodes it as JSON. Each string is decoded at most once,
+// all references to it share the value. Otherwise a few bytes referencing a huge string could take huge memory.
+func (d *binaryDecoder) jsonValue() (interface{}, error) {
+	raw, i, err := d.indexedString()
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := d.values[i]; ok {
+		return value, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, err
+	}
+
+	if d.values == nil {
+		d.values = map[int]interface{}{}
+	}
+
+	d.values[i] = value
+	return value, nil
+}
+
+// count reads an amount of items, each of which takes at least one byte.
+func (d *binaryDecoder) count() (int, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil || n > uint64(d.r.Len()) {
+		return 0, errBinaryMalformed
+	}
+
+	return int(n), nil
+}
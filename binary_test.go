@@ -0,0 +1,271 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdvancedError_MarshalBinary(t *testing.T) {
+	inner := AttachFieldsToError(io.EOF, 0, Field{"a", 1}, Field{"b", "x y"})
+	original := AttachFieldsToError(Wrap(inner, "reading"), 0, Field{"c", []int{1, 2}})
+
+	bin, err := original.(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	if jsn, _ := json.Marshal(original); len(bin) >= len(jsn) {
+		t.Errorf("AdvancedError#MarshalBinary(): got %d bytes, expected less than the JSON's %d", len(bin), len(jsn))
+	}
+
+	re := assertBinaryRoundTrip(t, original, bin)
+	assertSameJSON(t, re, original)
+
+	if me, ok := re.Err.(MessageError); !ok || me.Msg != "reading" || me.Err != plainError("EOF") {
+		t.Errorf("RemoteError#UnmarshalBinary(): got %#v, expected MessageError{\"reading\", \"EOF\"}", re.Err)
+	}
+
+	if actual, ok := LookupField(re, "a"); !ok || actual != 1.0 {
+		t.Errorf("LookupField(RemoteError, \"a\"): got %#v, %#v, expected 1.0, true", actual, ok)
+	}
+
+	if actual, err := re.MarshalBinary(); err != nil || !bytes.Equal(actual, bin) {
+		t.Errorf("RemoteError#MarshalBinary(): got %#v, %#v, expected %#v, nil", actual, err, bin)
+	}
+}
+
+func TestAdvancedError_MarshalBinary_Segments(t *testing.T) {
+	eg := NewErrorGroup(context.Background(), 0)
+	eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+
+	original := eg.Wait()
+
+	bin, err := original.(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	re := assertBinaryRoundTrip(t, original, bin)
+	assertSameJSON(t, re, original)
+
+	if len(re.Segments) != 1 || re.Segments[0].CreatedBy != "ErrorGroup.Go" {
+		t.Errorf("RemoteError#UnmarshalBinary(): got segments %#v, expected one by ErrorGroup.Go", re.Segments)
+	}
+}
+
+func TestAdvancedError_MarshalBinary_SourceContext(t *testing.T) {
+	SetSourceContext(1)
+	defer SetSourceContext(0)
+
+	original := AttachStackToError(io.EOF, 0)
+
+	bin, err := original.(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	expected := fmt.Sprintf("%+v", original)
+	SetSourceContext(0)
+
+	var re RemoteError
+	if err := re.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("RemoteError#UnmarshalBinary(%#v): got %#v, expected nil", bin, err)
+	}
+
+	if actual := fmt.Sprintf("%+v", re); actual != expected || !strings.Contains(actual, "> ") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", RemoteError): got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestAdvancedError_MarshalBinary_Redact(t *testing.T) {
+	original := AttachFieldsToError(io.EOF, 0, SecretField("token", "t0k3n"))
+
+	bin, err := original.(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	if bytes.Contains(bin, []byte("t0k3n")) || !bytes.Contains(bin, []byte("[REDACTED]")) {
+		t.Errorf("AdvancedError#MarshalBinary(): got %#v, expected the secret redacted", string(bin))
+	}
+}
+
+func TestAdvancedError_MarshalBinary_MultiError(t *testing.T) {
+	eg := NewCollectingErrorGroup(context.Background(), 1, 0)
+	eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+	eg.Go(1, errorGroupify(dumbSleeper(0), io.ErrUnexpectedEOF))
+
+	original := AttachFieldsToError(eg.Wait(), 0, Field{"a", 1})
+
+	bin, err := original.(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	if re := assertBinaryRoundTrip(t, original, bin); len(re.Frames) < 1 {
+		t.Errorf("RemoteError#UnmarshalBinary(): got no frames, expected the ones of the first error")
+	} else if me, ok := re.Err.(MultiError); !ok || len(me) != 2 {
+		t.Errorf("RemoteError#UnmarshalBinary(): got %#v, expected MultiError of two errors", re.Err)
+	}
+}
+
+func TestAdvancedError_MarshalBinary_Composite(t *testing.T) {
+	since := time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", 3600))
+	attempts := MultiError{AttachStackToError(io.EOF, 0), AttachStackToError(io.ErrUnexpectedEOF, 0)}
+
+	for _, original := range []ErrorWithStack{
+		RetryError{attempts, ErrMaxAttempts},
+		RepeatedError{AttachStackToError(io.EOF, 0), 3, since},
+		RepeatedError{io.EOF, 3, since},
+		CircuitOpenError{attempts[0], AttachStackToError(io.EOF, 0).StackTrace()},
+		MultiError{RetryError{attempts, context.Canceled}, CircuitOpenError{attempts[1], nil}},
+	} {
+		ae := AdvancedError{Err: original, Stack: AttachStackToError(io.EOF, 0).StackTrace()}
+
+		bin, err := ae.MarshalBinary()
+		if err != nil {
+			t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+		}
+
+		re := assertBinaryRoundTrip(t, ae, bin)
+
+		switch o := original.(type) {
+		case RetryError:
+			if r, ok := re.Err.(RetryError); !ok || r.Reason != o.Reason || len(r.Attempts) != len(o.Attempts) {
+				t.Errorf("RemoteError#UnmarshalBinary(): got %#v, expected a RetryError like %#v", re.Err, o)
+			}
+		case RepeatedError:
+			if r, ok := re.Err.(RepeatedError); !ok || r.Count != o.Count || !r.Since.Equal(o.Since) {
+				t.Errorf("RemoteError#UnmarshalBinary(): got %#v, expected a RepeatedError like %#v", re.Err, o)
+			}
+		case CircuitOpenError:
+			if r, ok := re.Err.(RemoteError); !ok || len(r.Frames) < 1 {
+				t.Errorf("RemoteError#UnmarshalBinary(): got %#v, expected a CircuitOpenError with stack", re.Err)
+			} else if _, ok := r.Err.(CircuitOpenError); !ok || RootCause(r).Error() != "EOF" {
+				t.Errorf("RootCause(%#v): got %#v, expected the last failure", r, RootCause(r))
+			}
+		}
+	}
+}
+
+func TestRemoteError_GobDecode(t *testing.T) {
+	original := AttachFieldsToError(io.EOF, 0, Field{"a", "b"})
+	buf := &bytes.Buffer{}
+
+	if err := gob.NewEncoder(buf).Encode(original.(AdvancedError)); err != nil {
+		t.Fatalf("gob.Encoder#Encode(AdvancedError): got %#v, expected nil", err)
+	}
+
+	var re RemoteError
+	if err := gob.NewDecoder(buf).Decode(&re); err != nil {
+		t.Fatalf("gob.Decoder#Decode(RemoteError): got %#v, expected nil", err)
+	}
+
+	if actual, expected := fmt.Sprintf("%+v", re), fmt.Sprintf("%+v", original); actual != expected {
+		t.Errorf("fmt.Sprintf(\"%%+v\", RemoteError): got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestRemoteError_UnmarshalBinary(t *testing.T) {
+	bin, err := AttachFieldsToError(Wrap(io.EOF, "reading"), 0, Field{"a", 1}).(AdvancedError).MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalBinary(): got %#v, expected nil", err)
+	}
+
+	var re RemoteError
+
+	for i := 0; i < len(bin); i++ {
+		if err := re.UnmarshalBinary(bin[:i]); err == nil {
+			t.Errorf("RemoteError#UnmarshalBinary(%#v): got nil, expected an error", bin[:i])
+		}
+	}
+
+	if err := re.UnmarshalBinary(append(append([]byte(nil), bin...), 0)); err != errBinaryMalformed {
+		t.Errorf("RemoteError#UnmarshalBinary(<trailing garbage>): got %#v, expected %#v", err, errBinaryMalformed)
+	}
+
+	if err := re.UnmarshalBinary([]byte{2, binaryPlain, 0, 0}); err != errBinaryVersion {
+		t.Errorf("RemoteError#UnmarshalBinary(<version 2>): got %#v, expected %#v", err, errBinaryVersion)
+	}
+
+	deep := []byte{binaryVersion}
+	for i := 0; i <= maxBinaryDepth; i++ {
+		deep = append(deep, binaryMessage, 0, 0)
+	}
+
+	if err := re.UnmarshalBinary(append(deep, binaryPlain, 0, 0)); err != errBinaryDepth {
+		t.Errorf("RemoteError#UnmarshalBinary(<deeply nested>): got %#v, expected %#v", err, errBinaryDepth)
+	}
+}
+
+func TestRemoteError_UnmarshalBinary_References(t *testing.T) {
+	const fields = 200000
+
+	// One 200 KB JSON string referenced by all field values
+	e := &binaryEncoder{refs: map[string]uint64{}}
+	e.buf.WriteByte(binaryVersion)
+	e.buf.WriteByte(binaryStack)
+	e.buf.WriteByte(binaryPlain)
+	e.string("boom")
+	e.uvarint(0)
+	e.uvarint(fields)
+
+	huge := `"` + strings.Repeat("x", 200<<10) + `"`
+	for i := 0; i < fields; i++ {
+		e.string("k")
+		e.string(huge)
+	}
+
+	var re RemoteError
+	if err := re.UnmarshalBinary(e.buf.Bytes()); err != nil {
+		t.Fatalf("RemoteError#UnmarshalBinary(<%d bytes>): got %#v, expected nil", e.buf.Len(), err)
+	}
+
+	if len(re.Fields) != fields || re.Fields[fields-1].Value != huge[1:len(huge)-1] {
+		t.Errorf("RemoteError#UnmarshalBinary(<%d bytes>): got %d fields, expected %d", e.buf.Len(), len(re.Fields), fields)
+	}
+}
+
+// assertBinaryRoundTrip decodes $bin and checks whether the result renders like $original.
+func assertBinaryRoundTrip(t *testing.T, original error, bin []byte) RemoteError {
+	t.Helper()
+
+	var re RemoteError
+	if err := re.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("RemoteError#UnmarshalBinary(%#v): got %#v, expected nil", bin, err)
+	}
+
+	if actual, expected := re.Error(), original.Error(); actual != expected {
+		t.Errorf("RemoteError#Error(): got %#v, expected %#v", actual, expected)
+	}
+
+	for _, format := range []string{"%s", "%v", "%+v"} {
+		if actual, expected := fmt.Sprintf(format, re), fmt.Sprintf(format, original); actual != expected {
+			t.Errorf("fmt.Sprintf(%#v, RemoteError): got %#v, expected %#v", format, actual, expected)
+		}
+	}
+
+	return re
+}
+
+// assertSameJSON checks whether $re marshals to the same JSON as $original.
+func assertSameJSON(t *testing.T, re RemoteError, original error) {
+	t.Helper()
+
+	actual, errRe := json.Marshal(re)
+	expected, errOrig := json.Marshal(original)
+
+	if errRe != nil || errOrig != nil || string(actual) != string(expected) {
+		t.Errorf(
+			"json.Marshal(RemoteError): got %#v, %#v, expected %#v, %#v",
+			string(actual), errRe, string(expected), errOrig,
+		)
+	}
+}
@@ -0,0 +1,269 @@
+package fuel
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState uint8
+
+const (
+	// CircuitClosed lets all calls through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls until CircuitPolicy#CoolDown passed.
+	CircuitOpen
+	// CircuitHalfOpen lets one trial call through at a time.
+	CircuitHalfOpen
+)
+
+func (cs CircuitState) String() string {
+	switch cs {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Clock tells the current time, e.g. a fake one in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct {
+}
+
+var _ Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// CircuitPolicy specifies when a CircuitBreaker opens and closes.
+type CircuitPolicy struct {
+	// MaxFailures, if positive, opens the circuit after that many consecutive failures.
+	MaxFailures int
+	// WindowFailures, if positive, opens the circuit after that many failures within Window.
+	WindowFailures int
+	Window         time.Duration
+	// CoolDown is how long the circuit stays open before it becomes half-open.
+	CoolDown time.Duration
+	// HalfOpenSuccesses is the amount of successful trial calls which close the circuit. Defaults to 1.
+	HalfOpenSuccesses int
+	// IsFailure, if not nil, tells whether an error counts as failure, e.g. not context.Canceled.
+	IsFailure func(err ErrorWithStack) bool
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// CircuitBreaker stops calling a failing function for a while once it failed too often.
+type CircuitBreaker struct {
+	// OnStateChange, if not nil, is called on every state change.
+	// It's called with the breaker locked, so it must not use the breaker.
+	OnStateChange func(from, to CircuitState)
+
+	policy      CircuitPolicy
+	mtx         sync.Mutex
+	state       CircuitState
+	consecutive int
+	failures    []time.Time
+	openedAt    time.Time
+	lastFailure ErrorWithStack
+	successes   int
+	trialActive bool
+}
+
+// NewCircuitBreaker creates a new closed CircuitBreaker.
+func NewCircuitBreaker(policy CircuitPolicy) *CircuitBreaker {
+	if policy.HalfOpenSuccesses < 1 {
+		policy.HalfOpenSuccesses = 1
+	}
+
+	if policy.Clock == nil {
+		policy.Clock = systemClock{}
+	}
+
+	return &CircuitBreaker{policy: policy}
+}
+
+// Call calls $f unless the circuit is open or half-open with a trial call in progress.
+// In that case it returns a CircuitOpenError (with the stack of the Call() caller) instead.
+// Panics of $f and runtime.Goexit() count as failures and are passed through.
+// Only panic(nil) can't be told apart from runtime.Goexit() and is returned as PanicError instead.
+func (cb *CircuitBreaker) Call(ctx context.Context, f func(context.Context) ErrorWithStack) (err ErrorWithStack) {
+	trial, open := cb.before()
+	if open != nil {
+		open.Stack = captureStack(1)
+		return *open
+	}
+
+	returned := false
+	defer func() {
+		if !returned {
+			r := recover()
+			failure := panicToError(r, nil)
+			cb.after(trial, failure)
+
+			if r != nil {
+				panic(r)
+			}
+
+			err = failure
+		}
+	}()
+
+	err = f(ctx)
+	returned = true
+	cb.after(trial, err)
+
+	return
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	cb.refresh()
+	return cb.state
+}
+
+// before decides whether to let a call through and whether that one is a trial.
+func (cb *CircuitBreaker) before() (trial bool, open *CircuitOpenError) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	cb.refresh()
+
+	switch cb.state {
+	case CircuitOpen:
+		return false, &CircuitOpenError{LastFailure: cb.lastFailure}
+	case CircuitHalfOpen:
+		if cb.trialActive {
+			return false, &CircuitOpenError{LastFailure: cb.lastFailure}
+		}
+
+		cb.trialActive = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// after records the result $err of a call let through by before.
+func (cb *CircuitBreaker) after(trial bool, err ErrorWithStack) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	failed := err != nil && (cb.policy.IsFailure == nil || cb.policy.IsFailure(err))
+
+	if trial {
+		cb.trialActive = false
+
+		if failed {
+			cb.open(err)
+		} else if cb.successes++; cb.successes >= cb.policy.HalfOpenSuccesses {
+			cb.setState(CircuitClosed)
+		}
+
+		return
+	}
+
+	if cb.state != CircuitClosed {
+		// A call let through before the circuit opened
+		return
+	}
+
+	if !failed {
+		cb.consecutive = 0
+		return
+	}
+
+	cb.consecutive++
+
+	if cb.policy.WindowFailures > 0 {
+		now := cb.policy.Clock.Now()
+		cb.failures = append(cb.failures, now)
+
+		for len(cb.failures) > 0 && now.Sub(cb.failures[0]) > cb.policy.Window {
+			cb.failures = cb.failures[1:]
+		}
+	}
+
+	if cb.policy.MaxFailures > 0 && cb.consecutive >= cb.policy.MaxFailures ||
+		cb.policy.WindowFailures > 0 && len(cb.failures) >= cb.policy.WindowFailures {
+		cb.open(err)
+	}
+}
+
+// refresh makes an open circuit half-open once the cool-down passed.
+func (cb *CircuitBreaker) refresh() {
+	if cb.state == CircuitOpen && cb.policy.Clock.Now().Sub(cb.openedAt) >= cb.policy.CoolDown {
+		cb.successes = 0
+		cb.setState(CircuitHalfOpen)
+	}
+}
+
+// open opens the circuit due to $err.
+func (cb *CircuitBreaker) open(err ErrorWithStack) {
+	cb.lastFailure = err
+	cb.openedAt = cb.policy.Clock.Now()
+	cb.setState(CircuitOpen)
+}
+
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	cb.state = state
+	cb.consecutive = 0
+	cb.failures = nil
+
+	if cb.OnStateChange != nil && from != state {
+		cb.OnStateChange(from, state)
+	}
+}
+
+// CircuitOpenError is returned by CircuitBreaker#Call() instead of calling the function while the circuit is open.
+type CircuitOpenError struct {
+	// LastFailure is the failure which opened the circuit.
+	LastFailure ErrorWithStack
+	// Stack is the one of the rejected CircuitBreaker#Call() caller.
+	Stack errors.StackTrace
+}
+
+var _ error = CircuitOpenError{}
+
+func (coe CircuitOpenError) Error() string {
+	return "circuit open: " + coe.LastFailure.Error()
+}
+
+var _ fmt.Formatter = CircuitOpenError{}
+
+// Format appends the stack, if any, on %+v and %v like AdvancedError#Format().
+func (coe CircuitOpenError) Format(fs fmt.State, verb rune) {
+	if len(coe.Stack) < 1 {
+		formatRedacted(fs, verb, coe.Error())
+		return
+	}
+
+	AdvancedError{Err: plainError(coe.Error()), Stack: coe.Stack}.Format(fs, verb)
+}
+
+var _ StackTracer = CircuitOpenError{}
+
+func (coe CircuitOpenError) StackTrace() errors.StackTrace {
+	return coe.Stack
+}
+
+var _ Unwrapper = CircuitOpenError{}
+
+func (coe CircuitOpenError) Unwrap() error {
+	return coe.LastFailure
+}
@@ -0,0 +1,202 @@
+package fuel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_MaxFailures(t *testing.T) {
+	clock := &fakeClock{time.Unix(0, 0)}
+	cb := NewCircuitBreaker(CircuitPolicy{MaxFailures: 3, CoolDown: time.Minute, HalfOpenSuccesses: 2, Clock: clock})
+
+	var transitions []string
+	cb.OnStateChange = func(from, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	}
+
+	var failure ErrorWithStack
+	fail := func(context.Context) ErrorWithStack {
+		failure = AttachStackToError(fmt.Errorf("attempt failed"), 0)
+		return failure
+	}
+
+	calls := 0
+	succeed := func(context.Context) ErrorWithStack {
+		calls++
+		return nil
+	}
+
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	assertCircuitCall(t, cb, succeed, CircuitClosed)
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	assertCircuitCall(t, cb, fail, CircuitOpen)
+
+	tripping := failure
+	err := cb.Call(context.Background(), succeed)
+
+	coe, ok := err.(CircuitOpenError)
+	if !ok || unwrapAdvanced(coe.LastFailure) != unwrapAdvanced(tripping) {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected a CircuitOpenError with the last failure", err)
+	} else if actual := fmt.Sprintf("%n", coe.Stack[0]); actual != "TestCircuitBreaker_MaxFailures" {
+		t.Errorf("CircuitBreaker#Call(...): got %s on top of the stack, expected TestCircuitBreaker_MaxFailures", actual)
+	}
+
+	if err == nil || RootCause(err).Error() != "attempt failed" || err.Error() != "circuit open: attempt failed" {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected it to wrap the last failure", err)
+	}
+
+	if text := fmt.Sprintf("%+v", err); !strings.Contains(text, "TestCircuitBreaker_MaxFailures\n") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected the stack of the Call() caller", err, text)
+	}
+
+	if calls != 1 {
+		t.Errorf("CircuitBreaker#Call(...): got %d calls, expected none while open", calls)
+	}
+
+	clock.Advance(time.Minute)
+
+	if state := cb.State(); state != CircuitHalfOpen {
+		t.Errorf("CircuitBreaker#State(): got %s, expected half-open after the cool-down", state)
+	}
+
+	assertCircuitCall(t, cb, fail, CircuitOpen)
+	clock.Advance(time.Minute)
+	assertCircuitCall(t, cb, succeed, CircuitHalfOpen)
+	assertCircuitCall(t, cb, succeed, CircuitClosed)
+
+	expected := "[closed->open open->half-open half-open->open open->half-open half-open->closed]"
+	if actual := fmt.Sprint(transitions); actual != expected {
+		t.Errorf("CircuitBreaker#OnStateChange: got %s, expected %s", actual, expected)
+	}
+}
+
+func TestCircuitBreaker_Window(t *testing.T) {
+	clock := &fakeClock{time.Unix(0, 0)}
+	cb := NewCircuitBreaker(
+		CircuitPolicy{WindowFailures: 3, Window: 10 * time.Second, CoolDown: time.Second, Clock: clock},
+	)
+
+	fail := func(context.Context) ErrorWithStack { return AttachStackToError(io.EOF, 0) }
+	succeed := func(context.Context) ErrorWithStack { return nil }
+
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	assertCircuitCall(t, cb, succeed, CircuitClosed)
+	clock.Advance(6 * time.Second)
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	clock.Advance(6 * time.Second)
+	assertCircuitCall(t, cb, fail, CircuitClosed)
+	clock.Advance(time.Second)
+	assertCircuitCall(t, cb, fail, CircuitOpen)
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	clock := &fakeClock{time.Unix(0, 0)}
+	cb := NewCircuitBreaker(CircuitPolicy{
+		MaxFailures: 1,
+		IsFailure:   func(err ErrorWithStack) bool { return RootCause(err) != context.Canceled },
+		Clock:       clock,
+	})
+
+	assertCircuitCall(t, cb, func(context.Context) ErrorWithStack {
+		return AttachStackToError(context.Canceled, 0)
+	}, CircuitClosed)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("CircuitBreaker#Call(...): got panic %#v, expected \"boom\"", r)
+			}
+		}()
+
+		cb.Call(context.Background(), func(context.Context) ErrorWithStack { panic("boom") })
+	}()
+
+	if state := cb.State(); state != CircuitHalfOpen {
+		t.Errorf("CircuitBreaker#State(): got %s, expected half-open after a panic and no cool-down", state)
+	}
+
+	inner := cb.Call(context.Background(), func(context.Context) ErrorWithStack {
+		// Another call during the trial one
+		return cb.Call(context.Background(), func(context.Context) ErrorWithStack { return nil })
+	})
+
+	if _, ok := unwrapAdvanced(inner).(CircuitOpenError); !ok {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected a CircuitOpenError during a trial call", inner)
+	}
+
+	if _, ok := RootCause(inner).(PanicError); !ok {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected the panic as last failure", inner)
+	}
+}
+
+func TestCircuitBreaker_AbnormalTrial(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitPolicy{MaxFailures: 1})
+
+	assertCircuitCall(t, cb, func(context.Context) ErrorWithStack {
+		return AttachStackToError(io.EOF, 0)
+	}, CircuitHalfOpen)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.Call(context.Background(), func(context.Context) ErrorWithStack { runtime.Goexit(); return nil })
+	}()
+	<-done
+
+	err := cb.Call(context.Background(), func(context.Context) ErrorWithStack { panic(nil) })
+	if ae, ok := err.(AdvancedError); !ok || ae.Err != (PanicError{}) {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected the panic(nil) after runtime.Goexit()", err)
+	}
+
+	err = cb.Call(context.Background(), func(context.Context) ErrorWithStack { return nil })
+	if err != nil {
+		t.Errorf("CircuitBreaker#Call(...): got %#v, expected the trial slot to be free", err)
+	}
+
+	if state := cb.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker#State(): got %s, expected closed after a successful trial", state)
+	}
+}
+
+func assertCircuitCall(t *testing.T, cb *CircuitBreaker, f func(context.Context) ErrorWithStack, state CircuitState) {
+	t.Helper()
+
+	cb.Call(context.Background(), f)
+
+	if actual := cb.State(); actual != state {
+		t.Errorf("CircuitBreaker#State(): got %s, expected %s", actual, state)
+	}
+}
+
+// unwrapAdvanced returns the first error wrapped by $err which isn't an AdvancedError.
+func unwrapAdvanced(err error) error {
+	for {
+		ae, ok := err.(AdvancedError)
+		if !ok {
+			return err
+		}
+
+		err = ae.Err
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+var _ Clock = (*fakeClock)(nil)
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
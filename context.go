@@ -0,0 +1,101 @@
+package fuel
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// ContextExtractor returns fields describing $ctx, e.g. the request ID or trace ID stored in it.
+// It shall return nothing for contexts it knows nothing about.
+type ContextExtractor func(ctx context.Context) []Field
+
+var (
+	contextExtractors    atomic.Value
+	contextExtractorsMtx sync.Mutex
+)
+
+func init() {
+	contextExtractors.Store([]ContextExtractor(nil))
+}
+
+// RegisterContextExtractor registers $extractor process-wide, typically from an init() function.
+// Extractors are called in the order of their registration.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	contextExtractorsMtx.Lock()
+	defer contextExtractorsMtx.Unlock()
+
+	extractors := contextExtractors.Load().([]ContextExtractor)
+	contextExtractors.Store(append(append([]ContextExtractor(nil), extractors...), extractor))
+}
+
+// ContextValueExtractor returns a ContextExtractor yielding a field named $key
+// with the value of $ctxKey in the context unless that's nil.
+func ContextValueExtractor(key string, ctxKey interface{}) ContextExtractor {
+	return func(ctx context.Context) []Field {
+		if value := ctx.Value(ctxKey); value != nil {
+			return []Field{{key, value}}
+		}
+
+		return nil
+	}
+}
+
+// FieldsFromContext returns the fields all extractors registered via RegisterContextExtractor return for $ctx.
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	var fields []Field
+	for _, extractor := range contextExtractors.Load().([]ContextExtractor) {
+		fields = append(fields, extractor(ctx)...)
+	}
+
+	return fields
+}
+
+// AttachStackToErrorContext is like AttachStackToError, but also attaches FieldsFromContext($ctx) to $err
+// (see AttachFieldsToError) except the ones whose keys $err already has (see LookupField).
+func AttachStackToErrorContext(ctx context.Context, err error, skip int) ErrorWithStack {
+	if err == nil {
+		return nil
+	}
+
+	fields := missingFields(err, FieldsFromContext(ctx))
+	if len(fields) < 1 {
+		return AttachStackToError(
+			err,
+			1+ // AttachStackToErrorContext
+				skip,
+		)
+	}
+
+	return AttachFieldsToError(
+		err,
+		1+ // AttachStackToErrorContext
+			skip,
+		fields...,
+	)
+}
+
+// annotateWithContext attaches FieldsFromContext($ctx) to $err as by AttachStackToErrorContext.
+func annotateWithContext(ctx context.Context, err ErrorWithStack) ErrorWithStack {
+	if fields := missingFields(err, FieldsFromContext(ctx)); len(fields) > 0 {
+		return AttachFieldsToError(err, 0, fields...)
+	}
+
+	return err
+}
+
+// missingFields returns the ones of $fields whose keys $err doesn't have yet.
+func missingFields(err error, fields []Field) []Field {
+	var missing []Field
+	for _, field := range fields {
+		if _, ok := LookupField(err, field.Key); !ok {
+			missing = append(missing, field)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,157 @@
+package fuel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testContextKey int
+
+const (
+	testRequestID testContextKey = iota
+	testTraceID
+)
+
+var registerTestExtractors sync.Once
+
+// withTestIDs returns $ctx with the request and trace IDs the extractors registered by this function extract.
+func withTestIDs(ctx context.Context, requestID, traceID string) context.Context {
+	registerTestExtractors.Do(func() {
+		RegisterContextExtractor(ContextValueExtractor("request_id", testRequestID))
+		RegisterContextExtractor(ContextValueExtractor("trace_id", testTraceID))
+	})
+
+	return context.WithValue(context.WithValue(ctx, testRequestID, requestID), testTraceID, traceID)
+}
+
+func TestContextValueExtractor(t *testing.T) {
+	extractor := ContextValueExtractor("request_id", testRequestID)
+
+	if actual := extractor(context.Background()); actual != nil {
+		t.Errorf("ContextValueExtractor(...)(context.Background()): got %#v, expected nil", actual)
+	}
+
+	ctx := context.WithValue(context.Background(), testRequestID, "r1")
+	if actual := extractor(ctx); !reflect.DeepEqual(actual, []Field{{"request_id", "r1"}}) {
+		t.Errorf("ContextValueExtractor(...)(%#v): got %#v, expected request_id=r1", ctx, actual)
+	}
+}
+
+func TestFieldsFromContext(t *testing.T) {
+	ctx := withTestIDs(context.Background(), "r1", "t1")
+
+	if actual := FieldsFromContext(nil); actual != nil {
+		t.Errorf("FieldsFromContext(nil): got %#v, expected nil", actual)
+	}
+
+	if actual := FieldsFromContext(context.Background()); actual != nil {
+		t.Errorf("FieldsFromContext(context.Background()): got %#v, expected nil", actual)
+	}
+
+	expected := []Field{{"request_id", "r1"}, {"trace_id", "t1"}}
+	if actual := FieldsFromContext(ctx); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FieldsFromContext(%#v): got %#v, expected %#v", ctx, actual, expected)
+	}
+}
+
+func TestAttachStackToErrorContext(t *testing.T) {
+	ctx := withTestIDs(context.Background(), "r1", "t1")
+
+	if actual := AttachStackToErrorContext(ctx, nil, 0); actual != nil {
+		t.Errorf("AttachStackToErrorContext(ctx, nil, 0): got %#v, expected nil", actual)
+	}
+
+	withStack := AttachStackToError(io.EOF, 0)
+	if actual := AttachStackToErrorContext(context.Background(), withStack, 0); !reflect.DeepEqual(actual, withStack) {
+		t.Errorf("AttachStackToErrorContext(ctx, %#v, 0): got %#v, expected it as is", withStack, actual)
+	}
+
+	err := AttachStackToErrorContext(ctx, io.EOF, 0)
+	ae, ok := err.(AdvancedError)
+	if !ok {
+		t.Fatalf("AttachStackToErrorContext(ctx, io.EOF, 0): got %#v, expected AdvancedError", err)
+	}
+
+	if fe, ok := ae.Err.(FieldsError); !ok || fe.Err != io.EOF {
+		t.Errorf("AttachStackToErrorContext(ctx, io.EOF, 0): got %#v, expected io.EOF wrapped", ae.Err)
+	}
+
+	if text := fmt.Sprintf("%+v", ae.Stack[0]); !strings.Contains(text, "TestAttachStackToErrorContext") {
+		t.Errorf("AttachStackToErrorContext(ctx, io.EOF, 0): got stack starting at %s, expected the caller", text)
+	}
+
+	if text := fmt.Sprintf("%+v", err); !strings.HasPrefix(text, "EOF\nrequest_id=r1 trace_id=t1\n") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected the fields", err, text)
+	}
+
+	if jsn, errJM := json.Marshal(err); errJM != nil ||
+		!strings.Contains(string(jsn), `"fields":{"request_id":"r1","trace_id":"t1"}`) {
+		t.Errorf("json.Marshal(%#v): got %#v, %#v, expected the fields", err, string(jsn), errJM)
+	}
+
+	inner := AttachFieldsToError(io.EOF, 0, Field{"request_id", "r0"})
+	err = AttachStackToErrorContext(ctx, Wrap(inner, "reading"), 0)
+	expected := []Field{{"request_id", "r0"}, {"trace_id", "t1"}}
+
+	if actual := GetFields(err); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetFields(AttachStackToErrorContext(ctx, ..., 0)): got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestErrorGroup_Context(t *testing.T) {
+	for _, collect := range []bool{false, true} {
+		var eg *ErrorGroup
+		if collect {
+			eg = NewCollectingErrorGroup(withTestIDs(context.Background(), "r1", "t1"), 0, 0)
+		} else {
+			eg = NewErrorGroup(withTestIDs(context.Background(), "r1", "t1"), 0)
+		}
+
+		eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+
+		err := eg.Wait()
+		expected := []Field{{"request_id", "r1"}, {"trace_id", "t1"}}
+
+		if actual := GetFields(err); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("GetFields(ErrorGroup#Wait()): got %#v, expected %#v", actual, expected)
+		}
+
+		if text := fmt.Sprintf("%+v", err); !strings.Contains(text, "request_id=r1 trace_id=t1") ||
+			!strings.Contains(text, "\ncreated by ErrorGroup.Go at errors.go:") {
+			t.Errorf("fmt.Sprintf(\"%%+v\", ErrorGroup#Wait()): got %s, expected the fields and both stacks", text)
+		}
+	}
+}
+
+func TestErrorGroup_Context_RePanic(t *testing.T) {
+	for _, collect := range []bool{false, true} {
+		var eg *ErrorGroup
+		if collect {
+			eg = NewCollectingErrorGroup(withTestIDs(context.Background(), "r1", "t1"), 0, 0)
+		} else {
+			eg = NewErrorGroup(withTestIDs(context.Background(), "r1", "t1"), 0)
+		}
+
+		eg.RePanic = true
+		eg.Go(1, func(context.Context) ErrorWithStack { panic(42) })
+
+		func() {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+
+				if _, found := LookupField(err, "request_id"); !ok || !found || RootCause(err) != (PanicError{42}) {
+					t.Errorf("ErrorGroup#Wait(): panicked with %#v, expected PanicError{42} with fields", r)
+				}
+			}()
+
+			eg.Wait()
+		}()
+	}
+}
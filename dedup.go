@@ -0,0 +1,330 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DedupOptions customize NewDedupReporter() and NewDedupWriter().
+type DedupOptions struct {
+	// Interval is how often repetitions of the same error are summarized at most. Defaults to 1m.
+	Interval time.Duration
+	// Fingerprint tells which errors are the same, see Fingerprint().
+	// Of errors without stack the messages have to match as well.
+	Fingerprint FingerprintOptions
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// RepeatedError summarizes suppressed repetitions of an error, see NewDedupReporter().
+type RepeatedError struct {
+	// Err is the last repetition.
+	Err error
+	// Count is the amount of repetitions since Since.
+	Count int
+	Since time.Time
+}
+
+var _ error = RepeatedError{}
+
+func (re RepeatedError) Error() string {
+	return re.summary() + re.Err.Error()
+}
+
+func (re RepeatedError) summary() string {
+	return fmt.Sprintf("repeated %d times since %s: ", re.Count, re.Since.Format(time.RFC3339))
+}
+
+var _ fmt.Formatter = RepeatedError{}
+
+// Format writes the summary followed by the last repetition as by the same $verb, e.g. with stack.
+func (re RepeatedError) Format(fs fmt.State, verb rune) {
+	io.WriteString(fs, re.summary())
+	formatRedacted(fs, verb, re.Err)
+}
+
+var _ json.Marshaler = RepeatedError{}
+
+func (re RepeatedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error    interface{} `json:"error"`
+		Repeated int         `json:"repeated"`
+		Since    time.Time   `json:"since"`
+	}{errorToJSONDocument(re.Err), re.Count, re.Since})
+}
+
+var _ StackTracer = RepeatedError{}
+
+// StackTrace returns the stack of the last repetition, if any.
+func (re RepeatedError) StackTrace() errors.StackTrace {
+	if st, ok := re.Err.(StackTracer); ok {
+		return st.StackTrace()
+	}
+
+	return nil
+}
+
+var _ fmt.Stringer = RepeatedError{}
+
+func (re RepeatedError) String() string {
+	s, _ := re.MarshalText()
+	return string(s)
+}
+
+var _ encoding.TextMarshaler = RepeatedError{}
+
+func (re RepeatedError) MarshalText() (text []byte, err error) {
+	buf := &bytes.Buffer{}
+	re.Format(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v')
+
+	return buf.Bytes(), nil
+}
+
+var _ Unwrapper = RepeatedError{}
+
+func (re RepeatedError) Unwrap() error {
+	return re.Err
+}
+
+// deduplicator lets the first occurrence of an error through and summarizes further ones as RepeatedError
+// every DedupOptions#Interval.
+type deduplicator struct {
+	opts      DedupOptions
+	mtx       sync.Mutex
+	seen      map[string]*dedupEntry
+	lastSweep time.Time
+}
+
+type dedupEntry struct {
+	lastEmit time.Time
+	count    int
+	last     error
+}
+
+func newDeduplicator(opts DedupOptions) deduplicator {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return deduplicator{opts: opts, seen: map[string]*dedupEntry{}, lastSweep: opts.Clock.Now()}
+}
+
+// filter returns what to pass through instead of $err: maybe $err itself and summaries of due repetitions.
+func (d *deduplicator) filter(err error) []error {
+	key := Fingerprint(err, d.opts.Fingerprint)
+	if outermostStackTracer(err) == nil {
+		key += "\n" + err.Error()
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := d.opts.Clock.Now()
+	var out []error
+
+	entry, ok := d.seen[key]
+	switch {
+	case !ok:
+		d.seen[key] = &dedupEntry{lastEmit: now}
+		out = append(out, err)
+	case now.Sub(entry.lastEmit) < d.opts.Interval:
+		entry.count++
+		entry.last = err
+	case entry.count > 0:
+		out = append(out, RepeatedError{err, entry.count + 1, entry.lastEmit})
+		*entry = dedupEntry{lastEmit: now}
+	default:
+		*entry = dedupEntry{lastEmit: now}
+		out = append(out, err)
+	}
+
+	if now.Sub(d.lastSweep) >= d.opts.Interval {
+		out = append(out, d.sweep(now, false)...)
+	}
+
+	return out
+}
+
+// due returns summaries of all due suppressed repetitions.
+func (d *deduplicator) due() []error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.sweep(d.opts.Clock.Now(), false)
+}
+
+// flush returns summaries of all suppressed repetitions, due or not.
+func (d *deduplicator) flush() []error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.sweep(d.opts.Clock.Now(), true)
+}
+
+// sweep summarizes the suppressed repetitions of all errors (if due or $all)
+// and forgets the ones without any for an interval.
+func (d *deduplicator) sweep(now time.Time, all bool) []error {
+	var out []error
+
+	for key, entry := range d.seen {
+		due := now.Sub(entry.lastEmit) >= d.opts.Interval
+
+		switch {
+		case entry.count > 0 && (due || all):
+			out = append(out, RepeatedError{entry.last, entry.count, entry.lastEmit})
+			*entry = dedupEntry{lastEmit: now}
+		case entry.count < 1 && due:
+			delete(d.seen, key)
+		}
+	}
+
+	d.lastSweep = now
+	return out
+}
+
+// DedupReporter passes the first occurrence of an error to another Reporter
+// and summarizes further ones as RepeatedError every DedupOptions#Interval.
+// Summaries are passed on further reports, every DedupOptions#Interval in the background and on Flush().
+type DedupReporter struct {
+	r Reporter
+	d deduplicator
+
+	close    sync.Once
+	stopping chan struct{}
+	closed   chan struct{}
+}
+
+// NewDedupReporter creates a new DedupReporter passing errors to $r. Close it not to leak goroutines!
+func NewDedupReporter(r Reporter, opts DedupOptions) *DedupReporter {
+	dr := &DedupReporter{r: r, d: newDeduplicator(opts), stopping: make(chan struct{}), closed: make(chan struct{})}
+
+	go dr.run()
+	return dr
+}
+
+var _ Reporter = (*DedupReporter)(nil)
+
+func (dr *DedupReporter) Report(err error) {
+	if err == nil {
+		return
+	}
+
+	for _, err := range dr.d.filter(err) {
+		dr.r.Report(err)
+	}
+}
+
+// Flush passes summaries of all suppressed repetitions and flushes the underlying Reporter.
+func (dr *DedupReporter) Flush(ctx context.Context) ErrorWithStack {
+	for _, err := range dr.d.flush() {
+		dr.r.Report(err)
+	}
+
+	return dr.r.Flush(ctx)
+}
+
+// Close stops passing due summaries in the background and then does Flush().
+// Further calls of Close are like Flush().
+func (dr *DedupReporter) Close(ctx context.Context) ErrorWithStack {
+	dr.close.Do(func() {
+		close(dr.stopping)
+	})
+
+	select {
+	case <-dr.closed:
+	default:
+		select {
+		case <-dr.closed:
+		case <-ctx.Done():
+			return AttachStackToError(ctx.Err(), 0)
+		}
+	}
+
+	return dr.Flush(ctx)
+}
+
+// run passes due summaries every DedupOptions#Interval until stopped.
+func (dr *DedupReporter) run() {
+	defer close(dr.closed)
+
+	ticker := time.NewTicker(dr.d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, err := range dr.d.due() {
+				dr.r.Report(err)
+			}
+		case <-dr.stopping:
+			return
+		}
+	}
+}
+
+// DedupWriter writes errors like DedupReporter reports them.
+type DedupWriter struct {
+	w        io.Writer
+	renderer ErrorRenderer
+	mtx      sync.Mutex
+	d        deduplicator
+}
+
+// NewDedupWriter creates a new DedupWriter writing errors rendered by $renderer, one per line, to $w.
+// A nil $renderer renders errors as by %+v.
+func NewDedupWriter(w io.Writer, renderer ErrorRenderer, opts DedupOptions) *DedupWriter {
+	return &DedupWriter{w: w, renderer: renderer, d: newDeduplicator(opts)}
+}
+
+// WriteError writes $err if not suppressed and due summaries.
+func (dw *DedupWriter) WriteError(err error) ErrorWithStack {
+	if err == nil {
+		return nil
+	}
+
+	return dw.write(dw.d.filter(err))
+}
+
+// Flush writes summaries of all suppressed repetitions.
+func (dw *DedupWriter) Flush() ErrorWithStack {
+	return dw.write(dw.d.flush())
+}
+
+func (dw *DedupWriter) write(errs []error) ErrorWithStack {
+	if len(errs) < 1 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	for _, err := range errs {
+		if dw.renderer == nil {
+			fmt.Fprintf(buf, "%+v\n", err)
+			continue
+		}
+
+		rendered, errRE := dw.renderer.RenderError(err)
+		if errRE != nil {
+			return AttachStackToError(errRE, 0)
+		}
+
+		buf.Write(rendered)
+		buf.WriteByte('\n')
+	}
+
+	dw.mtx.Lock()
+	defer dw.mtx.Unlock()
+
+	_, errWr := dw.w.Write(buf.Bytes())
+	return AttachStackToError(errWr, 0)
+}
@@ -0,0 +1,195 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	pkgerrors "github.com/pkg/errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupReporter(t *testing.T) {
+	clock := &fakeClock{time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	inner := &recordingReporter{}
+	dr := NewDedupReporter(inner, DedupOptions{Interval: time.Minute, Clock: clock})
+	defer dr.Close(context.Background())
+
+	failures := make([]ErrorWithStack, 0, 5)
+	for i := 0; i < 5; i++ {
+		failures = append(failures, dedupFailure(i))
+	}
+
+	other := AttachStackToError(errors.New("other"), 0)
+
+	dr.Report(failures[0])
+	dr.Report(failures[1])
+	dr.Report(other)
+	clock.Advance(30 * time.Second)
+	dr.Report(failures[2])
+	dr.Report(failures[3])
+	dr.Report(errors.New("plain 1"))
+	dr.Report(errors.New("plain 2"))
+	dr.Report(errors.New("plain 2"))
+
+	if expected := "[attempt 0 other plain 1 plain 2]"; fmt.Sprint(inner.messages()) != expected {
+		t.Errorf("DedupReporter: reported %v, expected %s", inner.messages(), expected)
+	}
+
+	clock.Advance(30 * time.Second)
+	dr.Report(failures[4])
+
+	expected := "[attempt 0 other plain 1 plain 2 repeated 4 times since 2000-01-01T00:00:00Z: attempt 4]"
+	if fmt.Sprint(inner.messages()) != expected {
+		t.Errorf("DedupReporter: reported %v, expected %s", inner.messages(), expected)
+	}
+
+	re, ok := inner.reported[len(inner.reported)-1].(RepeatedError)
+	if !ok || re.Err.Error() != "attempt 4" || re.Count != 4 || len(re.StackTrace()) < 1 {
+		t.Fatalf("DedupReporter: reported %#v, expected a RepeatedError with the last stack", inner.reported)
+	}
+
+	text := fmt.Sprintf("%+v", re)
+	if !strings.HasPrefix(text, "repeated 4 times since 2000-01-01T00:00:00Z: attempt 4\n") ||
+		!strings.Contains(text, "dedupFailure") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected the summary and the last stack", re, text)
+	}
+
+	var jsn struct {
+		Error struct {
+			Error string       `json:"error"`
+			Stack []StackFrame `json:"stack"`
+		} `json:"error"`
+		Repeated int       `json:"repeated"`
+		Since    time.Time `json:"since"`
+	}
+
+	if raw, err := json.Marshal(re); err != nil {
+		t.Errorf("json.Marshal(%#v): got error %#v", re, err)
+	} else if err := json.Unmarshal(raw, &jsn); err != nil || jsn.Error.Error != "attempt 4" ||
+		len(jsn.Error.Stack) < 1 || jsn.Repeated != 4 || !jsn.Since.Equal(re.Since) {
+		t.Errorf("json.Marshal(%#v): got %#v, expected the summary and the last stack", re, string(raw))
+	}
+
+	thirdParty := RepeatedError{Err: pkgerrors.New("third party"), Count: 2, Since: clock.Now()}
+	if raw, err := json.Marshal(thirdParty); err != nil {
+		t.Errorf("json.Marshal(%#v): got error %#v", thirdParty, err)
+	} else if err := json.Unmarshal(raw, &jsn); err != nil || len(jsn.Error.Stack) < 1 {
+		t.Errorf("json.Marshal(%#v): got %#v, expected the stack of the third-party error", thirdParty, string(raw))
+	}
+
+	dr.Report(failures[0])
+	dr.Report(errors.New("plain 2"))
+	inner.reported = nil
+
+	if err := dr.Flush(context.Background()); err != nil || !inner.flushed {
+		t.Errorf("DedupReporter#Flush(...): got %#v, expected the inner Reporter to be flushed", err)
+	}
+
+	messages := inner.messages()
+	sort.Strings(messages)
+
+	expected = "[repeated 1 times since 2000-01-01T00:01:00Z: attempt 0 " +
+		"repeated 2 times since 2000-01-01T00:00:30Z: plain 2]"
+
+	if fmt.Sprint(messages) != expected {
+		t.Errorf("DedupReporter#Flush(...): reported %v, expected %s", messages, expected)
+	}
+
+	clock.Advance(2 * time.Minute)
+	inner.reported = nil
+	dr.Report(failures[0])
+
+	if expected := "[attempt 0]"; fmt.Sprint(inner.messages()) != expected {
+		t.Errorf("DedupReporter: reported %v, expected %s after a quiet interval", inner.messages(), expected)
+	}
+}
+
+func TestDedupReporter_Close(t *testing.T) {
+	inner := &recordingReporter{}
+	dr := NewDedupReporter(inner, DedupOptions{Interval: 10 * time.Millisecond})
+
+	dr.Report(dedupFailure(0))
+	dr.Report(dedupFailure(1))
+	time.Sleep(50 * time.Millisecond)
+
+	// The background summary happens-before Close() returns.
+	if err := dr.Close(context.Background()); err != nil {
+		t.Errorf("DedupReporter#Close(...): got %#v, expected nil", err)
+	}
+
+	if messages := inner.messages(); len(messages) != 2 || !strings.HasPrefix(messages[1], "repeated 1 times since ") {
+		t.Errorf("DedupReporter: reported %v, expected a summary without further reports", messages)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := dr.Close(ctx); err != nil {
+		t.Errorf("DedupReporter#Close(...): got %#v, expected nil once closed", err)
+	}
+}
+
+func TestDedupWriter(t *testing.T) {
+	clock := &fakeClock{time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	buf := &bytes.Buffer{}
+	dw := NewDedupWriter(buf, LogfmtRenderer{}, DedupOptions{Clock: clock})
+
+	for i := 0; i < 3; i++ {
+		if err := dw.WriteError(dedupFailure(i)); err != nil {
+			t.Errorf("DedupWriter#WriteError(...): got %#v, expected nil", err)
+		}
+	}
+
+	if err := dw.Flush(); err != nil {
+		t.Errorf("DedupWriter#Flush(): got %#v, expected nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "error.message=\"attempt 0\" error.type=*errors.errorString ") ||
+		!strings.HasPrefix(lines[1], "error.message=\"repeated 2 times since 2000-01-01T00:00:00Z: attempt 2\" ") ||
+		!strings.Contains(lines[1], "dedupFailure") {
+		t.Errorf("DedupWriter: wrote %#v, expected the first occurrence and a summary", buf.String())
+	}
+
+	buf.Reset()
+	dw = NewDedupWriter(buf, nil, DedupOptions{Clock: clock})
+	dw.WriteError(dedupFailure(0))
+
+	if !strings.HasPrefix(buf.String(), "attempt 0\n") || !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("DedupWriter: wrote %#v, expected %%+v", buf.String())
+	}
+}
+
+func dedupFailure(i int) ErrorWithStack {
+	return AttachStackToError(fmt.Errorf("attempt %d", i), 0)
+}
+
+type recordingReporter struct {
+	reported []error
+	flushed  bool
+}
+
+var _ Reporter = (*recordingReporter)(nil)
+
+func (rr *recordingReporter) Report(err error) {
+	rr.reported = append(rr.reported, err)
+}
+
+func (rr *recordingReporter) Flush(context.Context) ErrorWithStack {
+	rr.flushed = true
+	return nil
+}
+
+func (rr *recordingReporter) messages() []string {
+	messages := make([]string, 0, len(rr.reported))
+	for _, err := range rr.reported {
+		messages = append(messages, err.Error())
+	}
+
+	return messages
+}
@@ -7,7 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"io"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -30,7 +35,17 @@ type Unwrapper interface {
 	Unwrap() error
 }
 
-// AttachStackToError attaches a complete errors.StackTrace of the calling goroutine to $err if needed,
+// Fielder is implemented by errors carrying key/value fields.
+type Fielder interface {
+	ErrorFields() []Field
+}
+
+// MultiUnwrapper is implemented by errors wrapping several errors at once (Go 1.20 style).
+type MultiUnwrapper interface {
+	Unwrap() []error
+}
+
+// AttachStackToError attaches an errors.StackTrace of the calling goroutine (see SetStackCapture) to $err if needed,
 // without AttachStackToError itself and $skip additional frames at the top.
 func AttachStackToError(err error, skip int) ErrorWithStack {
 	if err == nil {
@@ -43,13 +58,304 @@ func AttachStackToError(err error, skip int) ErrorWithStack {
 
 	return AdvancedError{
 		Err: err,
-		Stack: GetStack(
+		Stack: captureStack(
 			1 + // AttachStackToError
 				skip,
 		),
 	}
 }
 
+// AttachFieldsToError attaches $fields to $err, with a stack as by AttachStackToError if needed.
+// Fields already attached to $err are kept and precede $fields.
+func AttachFieldsToError(err error, skip int, fields ...Field) ErrorWithStack {
+	if err == nil {
+		return nil
+	}
+
+	switch e := err.(type) {
+	case AdvancedError:
+		e.Err = attachFields(e.Err, fields)
+		return e
+	case FilteredError:
+		return FilteredError{AttachFieldsToError(e.Err, 0, fields...), e.Filter}
+	case ErrorWithStack:
+		return AdvancedError{Err: FieldsError{e, fields}, Stack: e.StackTrace()}
+	default:
+		return AdvancedError{
+			Err: FieldsError{err, fields},
+			Stack: captureStack(
+				1 + // AttachFieldsToError
+					skip,
+			),
+		}
+	}
+}
+
+// attachFields wraps $err in a FieldsError with $fields or, if $err already is one, extends it.
+func attachFields(err error, fields []Field) FieldsError {
+	if fe, ok := err.(FieldsError); ok {
+		fe.Fields = append(append([]Field(nil), fe.Fields...), fields...)
+		return fe
+	}
+
+	return FieldsError{err, fields}
+}
+
+// Wrap adds $message as context to $err, with a stack as by AttachStackToError if needed.
+// If $err is an AdvancedError, its stack, fields etc. are kept.
+func Wrap(err error, message string) ErrorWithStack {
+	return wrap(err, message)
+}
+
+// Wrapf is like Wrap, but formats the message via fmt.Sprintf().
+func Wrapf(err error, format string, args ...interface{}) ErrorWithStack {
+	return wrap(err, fmt.Sprintf(format, args...))
+}
+
+// wrap implements Wrap and Wrapf which must call wrap directly.
+func wrap(err error, message string) ErrorWithStack {
+	if err == nil {
+		return nil
+	}
+
+	switch e := err.(type) {
+	case AdvancedError:
+		// Keep the fields outermost, so that MarshalJSON() still finds them.
+		if fe, ok := e.Err.(FieldsError); ok {
+			fe.Err = MessageError{message, fe.Err}
+			e.Err = fe
+		} else {
+			e.Err = MessageError{message, e.Err}
+		}
+
+		return e
+	case FilteredError:
+		return FilteredError{wrap(e.Err, message), e.Filter}
+	case ErrorWithStack:
+		if rendersOwnStack(e) {
+			// MessageError#Format() already writes the stack(s) of e.
+			return AdvancedError{Err: MessageError{message, e}}
+		}
+
+		return AdvancedError{Err: MessageError{message, e}, Stack: e.StackTrace()}
+	default:
+		return AdvancedError{
+			Err: MessageError{message, err},
+			Stack: captureStack(
+				1 + // wrap
+					1, // Wrap or Wrapf
+			),
+		}
+	}
+}
+
+// GetFields collects the fields of all errors wrapped by $err (see WalkError), outermost first.
+func GetFields(err error) []Field {
+	var fields []Field
+
+	WalkError(err, func(err error, _ int) bool {
+		if f, ok := err.(Fielder); ok {
+			fields = append(fields, f.ErrorFields()...)
+		}
+
+		return true
+	})
+
+	return fields
+}
+
+// LookupField returns the value of the newest field named $key in the errors wrapped by $err
+// like the one MarshalJSON() keeps: of the outermost error having such fields the last one.
+func LookupField(err error, key string) (value interface{}, ok bool) {
+	WalkError(err, func(err error, _ int) bool {
+		if f, isFielder := err.(Fielder); isFielder {
+			fields := f.ErrorFields()
+			for i := len(fields) - 1; i >= 0; i-- {
+				if fields[i].Key == key {
+					value, ok = fields[i].Value, true
+					return false
+				}
+			}
+		}
+
+		return true
+	})
+
+	return
+}
+
+// fieldsMapOf merges the fields of all errors wrapped by $err into a JSON object.
+// Fields of outer errors override the ones of inner errors. Of the same error the last one wins.
+func fieldsMapOf(err error) map[string]interface{} {
+	var perError [][]Field
+
+	WalkError(err, func(err error, _ int) bool {
+		if f, ok := err.(Fielder); ok {
+			perError = append(perError, f.ErrorFields())
+		}
+
+		return true
+	})
+
+	var fields []Field
+	for i := len(perError) - 1; i >= 0; i-- {
+		fields = append(fields, perError[i]...)
+	}
+
+	return fieldsToMap(fields)
+}
+
+// WalkError calls $visit for $err and all errors it wraps via Unwrap() []error, Unwrap() error or Cause()
+// (the first one implemented) depth-first, outer errors before inner ones. $depth is 0 for $err itself.
+// Each error is visited once, even in cyclic chains. WalkError stops once $visit returns false.
+func WalkError(err error, visit func(err error, depth int) bool) {
+	if err != nil {
+		walkError(err, 0, map[errorIdentity]struct{}{}, visit)
+	}
+}
+
+func walkError(err error, depth int, seen map[errorIdentity]struct{}, visit func(error, int) bool) bool {
+	if id, ok := identifyError(err); ok {
+		if _, ok := seen[id]; ok {
+			return true
+		}
+
+		seen[id] = struct{}{}
+	}
+
+	if !visit(err, depth) {
+		return false
+	}
+
+	for _, child := range unwrapError(err) {
+		if !walkError(child, depth+1, seen, visit) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unwrapError returns the errors wrapped by $err, except nil ones (incl. typed nil pointers).
+func unwrapError(err error) []error {
+	var children []error
+	switch e := err.(type) {
+	case MultiUnwrapper:
+		children = e.Unwrap()
+	case Unwrapper:
+		children = []error{e.Unwrap()}
+	case Causer:
+		children = []error{e.Cause()}
+	default:
+		return nil
+	}
+
+	nonNil := children[:0:0]
+	for _, child := range children {
+		if !isNilError(child) {
+			nonNil = append(nonNil, child)
+		}
+	}
+
+	return nonNil
+}
+
+// isNilError tells whether $err is nil or a nil pointer, map etc. of a type implementing error.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	switch v := reflect.ValueOf(err); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice, reflect.UnsafePointer:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// errorIdentity identifies errors which may be part of a cycle.
+type errorIdentity struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// identifyError returns the identity of $err if it references something which may reference $err again.
+// Only such errors may form cycles, other (value) ones would be infinitely large.
+func identifyError(err error) (errorIdentity, bool) {
+	v := reflect.ValueOf(err)
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return errorIdentity{v.Type(), v.Pointer(), 0}, true
+	case reflect.Slice:
+		return errorIdentity{v.Type(), v.Pointer(), v.Len()}, true
+	default:
+		return errorIdentity{}, false
+	}
+}
+
+// RootCause returns the innermost error wrapped by $err, following the first one of multiple wrapped errors.
+func RootCause(err error) error {
+	root := err
+
+	WalkError(err, func(err error, _ int) bool {
+		root = err
+		return len(unwrapError(err)) > 0
+	})
+
+	return root
+}
+
+// DeepestStackTracer returns the innermost error wrapped by $err which has a non-empty stack, if any.
+func DeepestStackTracer(err error) StackTracer {
+	var deepest StackTracer
+	maxDepth := -1
+
+	WalkError(err, func(err error, depth int) bool {
+		if st, ok := err.(StackTracer); ok && depth > maxDepth && hasStack(st) {
+			deepest = st
+			maxDepth = depth
+		}
+
+		return true
+	})
+
+	return deepest
+}
+
+func hasStack(st StackTracer) bool {
+	if len(st.StackTrace()) > 0 {
+		return true
+	}
+
+	f, ok := st.(Framer)
+	return ok && len(f.StackFrames()) > 0
+}
+
+// AllAs appends all errors wrapped by $err (see WalkError) assignable to the element type
+// of the slice $targets points to to that slice, outermost first.
+func AllAs(err error, targets interface{}) {
+	ptr := reflect.ValueOf(targets)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		panic("fuel: targets must be a non-nil pointer to a slice")
+	}
+
+	slice := ptr.Elem()
+	typ := slice.Type().Elem()
+
+	WalkError(err, func(err error, _ int) bool {
+		if v := reflect.ValueOf(err); v.Type().AssignableTo(typ) {
+			slice = reflect.Append(slice, v)
+		}
+
+		return true
+	})
+
+	ptr.Elem().Set(slice)
+}
+
 // GetStack returns a complete errors.StackTrace of the calling goroutine
 // without GetStack itself and $skip additional frames at the top.
 func GetStack(skip int) errors.StackTrace {
@@ -72,6 +378,69 @@ func GetStack(skip int) errors.StackTrace {
 	}
 }
 
+// Field is a typed key/value pair attached to an error, e.g. a request ID.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// FieldsError attaches typed key/value pairs to an error, see AttachFieldsToError.
+type FieldsError struct {
+	Err    error
+	Fields []Field
+}
+
+var _ Causer = FieldsError{}
+
+func (fe FieldsError) Cause() error {
+	return fe.Err
+}
+
+var _ error = FieldsError{}
+
+func (fe FieldsError) Error() string {
+	return fe.Err.Error()
+}
+
+var _ fmt.Formatter = FieldsError{}
+
+// Format appends the fields on %+v.
+func (fe FieldsError) Format(fs fmt.State, verb rune) {
+	formatRedacted(fs, verb, fe.Err)
+
+	if verb == 'v' && fs.Flag('+') {
+		formatFields(fs, fe.Fields)
+	}
+}
+
+var _ json.Marshaler = FieldsError{}
+
+// MarshalJSON writes the same as AdvancedError#MarshalJSON(), just without a stack.
+func (fe FieldsError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorJSON{Error: errorToJSON(fe.Err), Fields: fieldsToMap(fe.Fields)})
+}
+
+var _ Fielder = FieldsError{}
+
+func (fe FieldsError) ErrorFields() []Field {
+	return fe.Fields
+}
+
+var _ Unwrapper = FieldsError{}
+
+func (fe FieldsError) Unwrap() error {
+	return fe.Err
+}
+
+// peelFields returns $err and its fields if it's a FieldsError, $err itself otherwise.
+func peelFields(err error) (error, []Field) {
+	if fe, ok := err.(FieldsError); ok {
+		return fe.Err, fe.Fields
+	}
+
+	return err, nil
+}
+
 // AdvancedError is a feature-rich error wrapper.
 type AdvancedError struct {
 	Err   error
@@ -93,47 +462,102 @@ func (ae AdvancedError) Error() string {
 var _ fmt.Formatter = AdvancedError{}
 
 // Format appends the stack on %+v and %v.
+// On %+v the goroutines the stack spans are separated by "created by ... at ..." lines.
 func (ae AdvancedError) Format(fs fmt.State, verb rune) {
-	FormatNonFormatter(fs, verb, ae.Err)
+	ae.format(fs, verb, GetStackFilter())
+}
+
+// format implements Format, but renders the stack via $filter.
+func (ae AdvancedError) format(fs fmt.State, verb rune, filter *StackFilter) {
+	formatRedacted(fs, verb, ae.Err)
 
 	if verb == 'v' {
-		ae.Stack.Format(fs, verb)
+		ae.formatStack(fs, verb, filter)
 	}
 }
 
+// formatStack writes ae.Stack exactly like errors.StackTrace#Format() unless it has to be rendered
+// via $filter, with source code (see SetSourceContext) or, on %+v, with goroutine separators.
+// Either way the frames come from the cache of symbolizeStack.
+func (ae AdvancedError) formatStack(fs fmt.State, verb rune, filter *StackFilter) {
+	if filter == nil && GetSourceContext() < 1 {
+		if fs.Flag('#') {
+			ae.Stack.Format(fs, verb)
+			return
+		}
+
+		if frames := symbolizeStackFlat(ae.Stack); !fs.Flag('+') || len(stackSegments(frames)) < 1 {
+			formatFrames(fs, verb, frames)
+			return
+		}
+	}
+
+	formatSegments(fs, verb, ae.renderedSegments(filter))
+}
+
 var _ json.Marshaler = AdvancedError{}
 
 func (ae AdvancedError) MarshalJSON() ([]byte, error) {
-	type frame = struct {
-		File     string `json:"file,omitempty"`
-		Line     int    `json:"line,omitempty"`
-		Function string `json:"function,omitempty"`
-	}
+	return ae.marshalJSON(GetStackFilter())
+}
+
+// marshalJSON implements MarshalJSON, but renders the stack via $filter.
+func (ae AdvancedError) marshalJSON(filter *StackFilter) ([]byte, error) {
+	err, fields := peelFields(ae.Err)
+	stack, segments := joinSegments(ae.renderedSegments(filter))
 
-	var err interface{}
-	switch ae.Err.(type) {
+	return json.Marshal(errorJSON{errorToJSON(err), stack, segments, fieldsToMap(fields), jsonFingerprintOf(ae)})
+}
+
+// errorToJSON returns $err itself if it can marshal itself (redacted, see redactMarshaler),
+// its redacted message otherwise.
+func errorToJSON(err error) interface{} {
+	switch err.(type) {
 	case json.Marshaler, encoding.TextMarshaler:
-		err = ae.Err
+		return redactMarshaler(err)
 	default:
-		err = ae.Err.Error()
+		return GetRedactor().Redact(err.Error())
 	}
+}
 
-	var stack []frame
-	frames := runtime.CallersFrames(stackAsRaw(ae.Stack))
+// errorToJSONDocument returns $err itself if it can marshal itself to JSON (redacted, see redactMarshaler),
+// an AdvancedError with its stack (if it's a StackTracer) otherwise.
+func errorToJSONDocument(err error) interface{} {
+	switch e := err.(type) {
+	case json.Marshaler:
+		return redactMarshaler(e)
+	case StackTracer:
+		return AdvancedError{Err: err, Stack: e.StackTrace()}
+	default:
+		return AdvancedError{Err: err}
+	}
+}
 
-	for {
-		fr, ok := frames.Next()
-		if !ok {
-			break
-		}
+// renderedSegments returns the frames of ae.Stack passing $filter
+// with SourceContexts as specified by GetSourceContext().
+func (ae AdvancedError) renderedSegments(filter *StackFilter) []frameSegment {
+	frames := symbolizeStack(ae.Stack)
+	return withSourceContext(splitSegments(frames, stackSegments(frames), filter))
+}
 
-		stack = append(stack, frame{fr.File, fr.Line, fr.Function})
-	}
+// errorJSON is the JSON representation of AdvancedError and RemoteError.
+type errorJSON struct {
+	Error       interface{}            `json:"error"`
+	Stack       []StackFrame           `json:"stack,omitempty"`
+	Segments    []StackSegment         `json:"segments,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Fingerprint string                 `json:"fingerprint,omitempty"`
+}
 
-	return json.Marshal(struct {
-		Error interface{} `json:"error"`
-		Stack []frame     `json:"stack,omitempty"`
-	}{err, stack})
+var _ Framer = AdvancedError{}
+
+func (ae AdvancedError) StackFrames() []StackFrame {
+	return symbolizeStack(ae.Stack)
+}
+
+// StackSegments tells which parts of StackFrames() belong to which goroutines, e.g. if assembled by ErrorGroup.
+func (ae AdvancedError) StackSegments() []StackSegment {
+	return stackSegments(symbolizeStack(ae.Stack))
 }
 
 var _ StackTracer = AdvancedError{}
@@ -164,6 +588,513 @@ func (ae AdvancedError) Unwrap() error {
 	return ae.Err
 }
 
+// RemoteError is an error decoded from the JSON written by AdvancedError#MarshalJSON(), e.g. by another process.
+// It formats like the original AdvancedError, but has no errors.StackTrace, only StackFrames().
+type RemoteError struct {
+	// Message is the error message unless Err is set.
+	Message string
+	// Err is the decoded nested error, if any.
+	Err    error
+	Frames []StackFrame
+	Fields []Field
+	// Segments tell which parts of Frames belong to which goroutines, see AdvancedError.
+	Segments []StackSegment
+}
+
+var _ Causer = RemoteError{}
+
+func (re RemoteError) Cause() error {
+	return re.Err
+}
+
+var _ error = RemoteError{}
+
+func (re RemoteError) Error() string {
+	if re.Err != nil {
+		return re.Err.Error()
+	}
+
+	return re.Message
+}
+
+var _ fmt.Formatter = RemoteError{}
+
+// Format appends the fields on %+v and the stack on %+v and %v.
+func (re RemoteError) Format(fs fmt.State, verb rune) {
+	re.format(fs, verb, GetStackFilter())
+}
+
+// format implements Format, but renders the stack via $filter.
+func (re RemoteError) format(fs fmt.State, verb rune, filter *StackFilter) {
+	if re.Err != nil {
+		formatRedacted(fs, verb, re.Err)
+	} else {
+		formatRedacted(fs, verb, re.Message)
+	}
+
+	if verb == 'v' && fs.Flag('+') {
+		formatFields(fs, re.Fields)
+	}
+
+	if verb == 'v' {
+		formatSegments(fs, verb, re.renderedSegments(filter))
+	}
+}
+
+var _ json.Marshaler = RemoteError{}
+
+func (re RemoteError) MarshalJSON() ([]byte, error) {
+	return re.marshalJSON(GetStackFilter())
+}
+
+// marshalJSON implements MarshalJSON, but renders the stack via $filter.
+func (re RemoteError) marshalJSON(filter *StackFilter) ([]byte, error) {
+	var err interface{} = GetRedactor().Redact(re.Message)
+	if re.Err != nil {
+		err = re.Err
+	}
+
+	stack, segments := joinSegments(re.renderedSegments(filter))
+	return json.Marshal(errorJSON{err, stack, segments, fieldsToMap(re.Fields), jsonFingerprintOf(re)})
+}
+
+var _ json.Unmarshaler = (*RemoteError)(nil)
+
+// UnmarshalJSON decodes the JSON written by AdvancedError#MarshalJSON(). Nested errors become RemoteErrors.
+// Errors which marshaled themselves to neither a string nor such an object become just their JSON as Message.
+func (re *RemoteError) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Error    json.RawMessage            `json:"error"`
+		Stack    []StackFrame               `json:"stack"`
+		Segments []StackSegment             `json:"segments"`
+		Fields   map[string]json.RawMessage `json:"fields"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*re = RemoteError{}
+	re.Frames, re.Segments = raw.Stack, raw.Segments
+
+	var err error
+	if re.Message, re.Err, err = decodeErrorJSON(raw.Error); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(raw.Fields))
+	for k := range raw.Fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var v interface{}
+		if err := json.Unmarshal(raw.Fields[k], &v); err != nil {
+			return err
+		}
+
+		re.Fields = append(re.Fields, Field{k, v})
+	}
+
+	return nil
+}
+
+// decodeErrorJSON decodes the "error" of the JSON written by AdvancedError#MarshalJSON()
+// into either a $message or a $nested error.
+func decodeErrorJSON(data json.RawMessage) (message string, nested error, err error) {
+	if len(data) < 1 {
+		return
+	}
+
+	switch data[0] {
+	case '"':
+		err = json.Unmarshal(data, &message)
+		return
+	case '{':
+		var probe map[string]json.RawMessage
+		if err = json.Unmarshal(data, &probe); err != nil {
+			return
+		}
+
+		if inner, ok := probe["error"]; ok {
+			if msg, ok := probe["message"]; ok {
+				var me MessageError
+				if err = json.Unmarshal(msg, &me.Msg); err != nil {
+					return
+				}
+
+				var innerMsg string
+				if innerMsg, me.Err, err = decodeErrorJSON(inner); err != nil {
+					return
+				}
+
+				if me.Err == nil {
+					me.Err = plainError(innerMsg)
+				}
+
+				nested = me
+				return
+			}
+
+			var re RemoteError
+			err = json.Unmarshal(data, &re)
+			nested = re
+			return
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err = json.Compact(buf, data); err == nil {
+		message = buf.String()
+	}
+
+	return
+}
+
+// plainError is just an error message, e.g. a decoded one.
+type plainError string
+
+var _ error = plainError("")
+
+func (pe plainError) Error() string {
+	return string(pe)
+}
+
+var _ Fielder = RemoteError{}
+
+func (re RemoteError) ErrorFields() []Field {
+	return re.Fields
+}
+
+var _ Framer = RemoteError{}
+
+func (re RemoteError) StackFrames() []StackFrame {
+	return re.Frames
+}
+
+// renderedSegments returns the frames of re.Frames passing $filter.
+// They get no SourceContexts from GetSourceContext() as they may refer to any file.
+func (re RemoteError) renderedSegments(filter *StackFilter) []frameSegment {
+	return splitSegments(re.Frames, re.Segments, filter)
+}
+
+var _ StackTracer = RemoteError{}
+
+// StackTrace returns nil as the frames don't belong to the current process. Use StackFrames() instead.
+func (re RemoteError) StackTrace() errors.StackTrace {
+	return nil
+}
+
+var _ fmt.Stringer = RemoteError{}
+
+func (re RemoteError) String() string {
+	s, _ := re.MarshalText()
+	return string(s)
+}
+
+var _ encoding.TextMarshaler = RemoteError{}
+
+func (re RemoteError) MarshalText() (text []byte, err error) {
+	buf := &bytes.Buffer{}
+	re.Format(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v')
+
+	return buf.Bytes(), nil
+}
+
+var _ Unwrapper = RemoteError{}
+
+func (re RemoteError) Unwrap() error {
+	return re.Err
+}
+
+// MessageError adds context to an error, see Wrap.
+type MessageError struct {
+	Msg string
+	Err error
+}
+
+var _ Causer = MessageError{}
+
+func (me MessageError) Cause() error {
+	return me.Err
+}
+
+var _ error = MessageError{}
+
+func (me MessageError) Error() string {
+	return me.Msg + ": " + me.Err.Error()
+}
+
+var _ fmt.Formatter = MessageError{}
+
+// Format writes the message and the one of the wrapped error as own lines on %+v.
+// A stack of a wrapped third-party error isn't written as the enclosing AdvancedError (see Wrap) already does that.
+// Wrapped errors of this package are formatted completely, incl. their stacks.
+func (me MessageError) Format(fs fmt.State, verb rune) {
+	if verb != 'v' || !fs.Flag('+') {
+		formatRedacted(fs, verb, me.Error())
+		return
+	}
+
+	io.WriteString(fs, GetRedactor().Redact(me.Msg))
+	io.WriteString(fs, "\n")
+
+	if rendersOwnStack(me.Err) {
+		formatRedacted(fs, verb, me.Err)
+		return
+	}
+
+	if st, ok := me.Err.(StackTracer); ok && len(st.StackTrace()) > 0 {
+		formatRedacted(&Formatable{Output: fs}, verb, me.Err)
+	} else {
+		formatRedacted(fs, verb, me.Err)
+	}
+}
+
+// ownError tells whether $v is an error of this package, i.e. one which renders itself through GetRedactor(),
+// and whether it also writes its stack(s) itself on %+v.
+func ownError(v interface{}) (own, rendersStack bool) {
+	switch v.(type) {
+	case AdvancedError, RemoteError, FilteredError, MultiError, RetryError, RepeatedError, CircuitOpenError:
+		return true, true
+	case FieldsError, MessageError:
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// rendersOwnStack tells whether $err is of this package and writes its stack(s) itself on %+v, see ownError.
+func rendersOwnStack(err error) bool {
+	_, rendersStack := ownError(err)
+	return rendersStack
+}
+
+var _ json.Marshaler = MessageError{}
+
+func (me MessageError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string      `json:"message"`
+		Error   interface{} `json:"error"`
+	}{GetRedactor().Redact(me.Msg), errorToJSON(me.Err)})
+}
+
+var _ Unwrapper = MessageError{}
+
+func (me MessageError) Unwrap() error {
+	return me.Err
+}
+
+// PanicError is a recovered panic, see ErrorGroup and RunQueue.
+type PanicError struct {
+	Value interface{}
+}
+
+var _ error = PanicError{}
+
+func (pe PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
+}
+
+var _ Unwrapper = PanicError{}
+
+// Unwrap returns Value if it's an error.
+func (pe PanicError) Unwrap() error {
+	err, _ := pe.Value.(error)
+	return err
+}
+
+// panicToError converts the recovered panic $value to an ErrorWithStack with the stack of the panicking goroutine
+// and the $spawnStack of the goroutine which spawned it, if any.
+// The stack is captured as specified by GetStackCapture(), but its top is where the panic happened.
+// It must be called directly in the deferred function which recovered the panic.
+func panicToError(value interface{}, spawnStack errors.StackTrace) ErrorWithStack {
+	policy := GetStackCapture()
+	if !policy.sample() {
+		return AdvancedError{Err: PanicError{value}, Stack: appendSpawnStack(nil, spawnStack)}
+	}
+
+	stack := GetStack(
+		1 + // panicToError
+			1, // deferred function
+	)
+
+	raw := stackAsRaw(stack)
+	for i, pc := range raw {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			for i++; i < len(raw); i++ {
+				if fn := runtime.FuncForPC(raw[i] - 1); fn == nil || funcPackage(fn.Name()) != "runtime" {
+					break
+				}
+			}
+
+			stack = stack[i:]
+			break
+		}
+	}
+
+	if policy.Mode == CaptureShallow && len(stack) > policy.Depth {
+		stack = stack[:policy.Depth]
+	}
+
+	return AdvancedError{Err: PanicError{value}, Stack: appendSpawnStack(stack, spawnStack)}
+}
+
+// formatFields writes $fields logfmt-style as an own line.
+func formatFields(fs fmt.State, fields []Field) {
+	if len(fields) < 1 {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	r := GetRedactor()
+
+	for i, field := range fields {
+		if i == 0 {
+			buf.WriteByte('\n')
+		} else {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		buf.WriteString(quoteFieldValue(fmt.Sprintf("%+v", r.redactField(field.Key, field.Value))))
+	}
+
+	fs.Write(buf.Bytes())
+}
+
+// quoteFieldValue quotes $value if it wouldn't be unambiguous as a key=value pair's value otherwise.
+func quoteFieldValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =") || strconv.Quote(value) != `"`+value+`"` {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// fieldsToMap converts $fields to a JSON object with the values redacted via GetRedactor().
+// Later fields override earlier ones with the same key.
+func fieldsToMap(fields []Field) map[string]interface{} {
+	if len(fields) < 1 {
+		return nil
+	}
+
+	r := GetRedactor()
+	m := make(map[string]interface{}, len(fields))
+
+	for _, field := range fields {
+		m[field.Key] = r.redactField(field.Key, field.Value)
+	}
+
+	return m
+}
+
+// MultiError bundles several errors, e.g. all failed tasks of an ErrorGroup.
+type MultiError []ErrorWithStack
+
+var _ error = MultiError(nil)
+
+func (me MultiError) Error() string {
+	if len(me) == 1 {
+		return me[0].Error()
+	}
+
+	msgs := make([]string, 0, len(me))
+	for _, err := range me {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strconv.FormatInt(int64(len(me)), 10) + " errors occurred: " + strings.Join(msgs, "; ")
+}
+
+var _ fmt.Formatter = MultiError(nil)
+
+// Format formats all errors on %+v and %v, each one with its stack.
+func (me MultiError) Format(fs fmt.State, verb rune) {
+	if verb != 'v' {
+		formatRedacted(fs, verb, me.Error())
+		return
+	}
+
+	plus := fs.Flag('+')
+	if plus {
+		fmt.Fprintf(fs, "%d errors occurred:", len(me))
+	}
+
+	for i, err := range me {
+		if plus {
+			fmt.Fprintf(fs, "\n\n#%d: ", i+1)
+		} else if i > 0 {
+			fs.Write([]byte("; "))
+		}
+
+		formatRedacted(fs, verb, err)
+	}
+}
+
+var _ json.Marshaler = MultiError(nil)
+
+func (me MultiError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Errors []interface{} `json:"errors"`
+	}{me.toJSON()})
+}
+
+// toJSON returns the errors of $me as to be marshaled, i.e. with their stacks.
+func (me MultiError) toJSON() []interface{} {
+	errs := make([]interface{}, 0, len(me))
+	for _, err := range me {
+		if _, ok := err.(json.Marshaler); ok {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, AdvancedError{Err: err, Stack: err.StackTrace()})
+		}
+	}
+
+	return errs
+}
+
+var _ StackTracer = MultiError(nil)
+
+// StackTrace returns the stack of the first error.
+func (me MultiError) StackTrace() errors.StackTrace {
+	if len(me) < 1 {
+		return nil
+	}
+
+	return me[0].StackTrace()
+}
+
+var _ fmt.Stringer = MultiError(nil)
+
+func (me MultiError) String() string {
+	s, _ := me.MarshalText()
+	return string(s)
+}
+
+var _ encoding.TextMarshaler = MultiError(nil)
+
+func (me MultiError) MarshalText() (text []byte, err error) {
+	buf := &bytes.Buffer{}
+	me.Format(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v')
+
+	return buf.Bytes(), nil
+}
+
+var _ MultiUnwrapper = MultiError(nil)
+
+func (me MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(me))
+	for _, err := range me {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
 // errors.StackTrace is []uintptr
 var (
 	_ []errors.Frame = errors.StackTrace(nil)
@@ -181,16 +1112,26 @@ func stackAsRaw(stack errors.StackTrace) []uintptr {
 // * context is forwarded to tasks
 // * optional concurrency limit
 // * stops on context cancellation
+// * optionally collects all errors, not just the first one
+// * recovers panics of tasks as PanicError
 type ErrorGroup struct {
-	cancel func()
-	ctx    context.Context
-	err    ErrorWithStack
-	once   sync.Once
-	queued uintptr
-	rq     RunQueue
+	// RePanic makes Wait() panic with the error of a panicked task instead of returning it.
+	RePanic bool
+
+	cancel  func()
+	ctx     context.Context
+	err     ErrorWithStack
+	once    sync.Once
+	queued  uintptr
+	rq      RunQueue
+	collect bool
+	maxErrs int
+	errs    MultiError
+	errsMtx sync.Mutex
 }
 
 // NewErrorGroup creates a new ErrorGroup. $ctx is forwarded to tasks. $concurrency < 1 means infinite.
+// Errors of tasks get the fields FieldsFromContext returns for $ctx (see AttachStackToErrorContext).
 func NewErrorGroup(ctx context.Context, concurrency int64) *ErrorGroup {
 	myctx, cancel := context.WithCancel(ctx)
 	eg := &ErrorGroup{cancel: cancel, ctx: myctx}
@@ -204,6 +1145,17 @@ func NewErrorGroup(ctx context.Context, concurrency int64) *ErrorGroup {
 	return eg
 }
 
+// NewCollectingErrorGroup creates a new ErrorGroup which doesn't stop on the first error,
+// but collects all errors into a MultiError. It stops once $maxErrors errors have been collected.
+// $maxErrors < 1 means infinite. See NewErrorGroup for $ctx and $concurrency.
+func NewCollectingErrorGroup(ctx context.Context, concurrency int64, maxErrors int) *ErrorGroup {
+	eg := NewErrorGroup(ctx, concurrency)
+	eg.collect = true
+	eg.maxErrs = maxErrors
+
+	return eg
+}
+
 func (eg *ErrorGroup) Go(weight int64, f func(context.Context) ErrorWithStack) {
 	atomic.AddUintptr(&eg.queued, 1)
 
@@ -213,31 +1165,107 @@ func (eg *ErrorGroup) Go(weight int64, f func(context.Context) ErrorWithStack) {
 	default:
 	}
 
-	stack := GetStack(0)
+	stack := captureSpawnStack(0)
 	eg.rq.Enqueue(weight, func(ctx context.Context) {
 		atomic.AddUintptr(&eg.queued, ^uintptr(0))
 
-		if err := f(ctx); err != nil {
-			eg.once.Do(func() {
-				if ae, ok := err.(AdvancedError); ok {
-					err = AdvancedError{ae.Err, append(append(errors.StackTrace(nil), ae.Stack...), stack...)}
-				} else {
-					err = AdvancedError{err, append(append(errors.StackTrace(nil), err.StackTrace()...), stack...)}
+		callRecovering(ctx, f, func(err ErrorWithStack) {
+			err = annotateWithContext(ctx, err)
+
+			if eg.collect {
+				eg.errsMtx.Lock()
+				eg.errs = append(eg.errs, joinStacks(err, stack))
+
+				if eg.maxErrs > 0 && len(eg.errs) >= eg.maxErrs {
+					eg.cancel()
 				}
 
-				eg.err = err
-				eg.cancel()
-			})
-		}
+				eg.errsMtx.Unlock()
+			} else {
+				eg.once.Do(func() {
+					eg.err = joinStacks(err, stack)
+					eg.cancel()
+				})
+			}
+		})
 	})
 }
 
+// Wait waits for all tasks to finish and returns the first error
+// or, if created via NewCollectingErrorGroup, a MultiError of all errors.
 func (eg *ErrorGroup) Wait() ErrorWithStack {
 	eg.rq.Wait()
 
-	if eg.err == nil && atomic.LoadUintptr(&eg.queued) > 0 {
+	if eg.collect {
+		eg.errsMtx.Lock()
+		errs := append(MultiError(nil), eg.errs...)
+		eg.errsMtx.Unlock()
+
+		if len(errs) > 0 {
+			if eg.RePanic {
+				for _, err := range errs {
+					rePanic(err)
+				}
+			}
+
+			return errs
+		}
+	} else if eg.err != nil {
+		if eg.RePanic {
+			rePanic(eg.err)
+		}
+
+		return eg.err
+	}
+
+	if atomic.LoadUintptr(&eg.queued) > 0 {
 		return AttachStackToError(eg.ctx.Err(), 0)
 	}
 
-	return eg.err
+	return nil
+}
+
+// callRecovering calls $f with $ctx and $handle with its non-nil error. Any exit of $f other than a return,
+// i.e. a panic (even panic(nil)) or runtime.Goexit(), is converted to a PanicError and passed to $handle, too.
+func callRecovering(ctx context.Context, f func(context.Context) ErrorWithStack, handle func(ErrorWithStack)) {
+	var err ErrorWithStack
+	returned := false
+
+	defer func() {
+		if !returned {
+			err = panicToError(recover(), nil)
+		}
+
+		if err != nil {
+			handle(err)
+		}
+	}()
+
+	err = f(ctx)
+	returned = true
+}
+
+// rePanic panics with $err if it wraps a PanicError (see WalkError), e.g. along with fields.
+func rePanic(err ErrorWithStack) {
+	panicked := false
+	WalkError(err, func(err error, _ int) bool {
+		_, panicked = err.(PanicError)
+		return !panicked
+	})
+
+	if panicked {
+		panic(err)
+	}
+}
+
+// joinStacks appends $stack of the goroutine which called ErrorGroup#Go() to the one of $err.
+func joinStacks(err ErrorWithStack, stack errors.StackTrace) ErrorWithStack {
+	ae, ok := err.(AdvancedError)
+	if !ok {
+		ae = AdvancedError{Err: err, Stack: err.StackTrace()}
+	}
+
+	ae.Stack = appendSpawnStack(ae.Stack, stack)
+
+	return ae
 }
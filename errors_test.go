@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"io"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -51,6 +52,293 @@ func TestAttachStackToError(t *testing.T) {
 	}
 }
 
+func TestAttachFieldsToError(t *testing.T) {
+	if actual := AttachFieldsToError(nil, 0, Field{"a", 1}); actual != nil {
+		t.Errorf("AttachFieldsToError(nil, 0, ...): got %#v, expected nil", actual)
+	}
+
+	err := AttachFieldsToError(io.EOF, 0, Field{"a", 1})
+	if ae, ok := err.(AdvancedError); !ok || errors.Cause(ae) != io.EOF || len(ae.Stack) < 1 ||
+		len(GetFields(ae)) != 1 {
+		t.Errorf("AttachFieldsToError(io.EOF, 0, ...): got %#v, expected AdvancedError with stack and 1 field", err)
+	}
+
+	wrapped := AttachFieldsToError(err, 0, Field{"b", "x y"})
+	if ae, ok := wrapped.(AdvancedError); !ok || len(ae.Err.(FieldsError).Fields) != 2 || len(GetFields(err)) != 1 {
+		t.Errorf("AttachFieldsToError(%#v, 0, ...): got %#v, expected AdvancedError with 2 fields", err, wrapped)
+	}
+
+	thirdParty := errors.New("")
+	if ae, ok := AttachFieldsToError(thirdParty, 0, Field{"a", 1}).(AdvancedError); !ok ||
+		ae.Err.(FieldsError).Err != thirdParty || len(ae.Stack) != len(thirdParty.(ErrorWithStack).StackTrace()) {
+		t.Errorf("AttachFieldsToError(%#v, 0, ...): got %#v, expected AdvancedError with its stack", thirdParty, ae)
+	}
+}
+
+func TestFieldsError(t *testing.T) {
+	fe := FieldsError{io.EOF, []Field{{"a", 1}}}
+
+	if actual := fmt.Sprintf("%+v", fe); actual != "EOF\na=1" {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected \"EOF\\na=1\"", fe, actual)
+	}
+
+	if actual := fmt.Sprintf("%s", fe); actual != "EOF" {
+		t.Errorf("fmt.Sprintf(\"%%s\", %#v): got %#v, expected \"EOF\"", fe, actual)
+	}
+
+	jsn, err := json.Marshal(MessageError{"x", fe})
+	if err != nil {
+		t.Fatalf("json.Marshal(MessageError{\"x\", %#v}): got %#v, expected nil", fe, err)
+	}
+
+	var re RemoteError
+	if err := json.Unmarshal([]byte(`{"error":`+string(jsn)+`}`), &re); err != nil {
+		t.Errorf("RemoteError#UnmarshalJSON(%#v): got %#v, expected nil", string(jsn), err)
+	} else if actual, ok := LookupField(re, "a"); !ok || actual != 1.0 {
+		t.Errorf("LookupField(RemoteError, \"a\"): got %#v, %#v, expected 1, true", actual, ok)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if actual := Wrap(nil, "x"); actual != nil {
+		t.Errorf("Wrap(nil, \"x\"): got %#v, expected nil", actual)
+	}
+
+	if actual := Wrapf(nil, "%d", 1); actual != nil {
+		t.Errorf("Wrapf(nil, \"%%d\", 1): got %#v, expected nil", actual)
+	}
+
+	inner := Wrap(io.EOF, "reading")
+	if ae, ok := inner.(AdvancedError); !ok || ae.Err != (MessageError{"reading", io.EOF}) {
+		t.Errorf("Wrap(io.EOF, \"reading\"): got %#v, expected AdvancedError{Err: MessageError{...}}", inner)
+	} else if actual := fmt.Sprintf("%n", ae.Stack[0]); actual != "TestWrap" {
+		t.Errorf("Wrap(io.EOF, \"reading\"): got %s on top of the stack, expected TestWrap", actual)
+	}
+
+	outer := Wrapf(AttachFieldsToError(inner, 0, Field{"a", 1}), "loading %s", "config")
+
+	if actual := outer.Error(); actual != "loading config: reading: EOF" {
+		t.Errorf("Wrapf(...).Error(): got %#v", actual)
+	}
+
+	if actual := errors.Cause(outer); actual != io.EOF {
+		t.Errorf("errors.Cause(%#v): got %#v, expected io.EOF", outer, actual)
+	}
+
+	unwrapped := outer.(Unwrapper).Unwrap().(Unwrapper).Unwrap().(Unwrapper).Unwrap().(Unwrapper).Unwrap()
+	if unwrapped != io.EOF {
+		t.Errorf("Wrapf(...).Unwrap().Unwrap().Unwrap().Unwrap(): got %#v, expected io.EOF", unwrapped)
+	}
+
+	if len(outer.StackTrace()) != len(inner.StackTrace()) {
+		t.Errorf("Wrapf(...).StackTrace(): got %d frames, expected %d", len(outer.StackTrace()), len(inner.StackTrace()))
+	}
+
+	expected := "loading config\nreading\nEOF\na=1" + fmt.Sprintf("%+v", formatterFunc(func(fs fmt.State, verb rune) {
+		formatFrames(fs, verb, symbolizeStack(inner.StackTrace()))
+	}))
+	if actual := fmt.Sprintf("%+v", outer); actual != expected {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected %#v", outer, actual, expected)
+	}
+
+	if actual := fmt.Sprintf("%s", outer); actual != "loading config: reading: EOF" {
+		t.Errorf("fmt.Sprintf(\"%%s\", %#v): got %#v", outer, actual)
+	}
+
+	thirdParty := errors.New("x")
+	if actual := fmt.Sprintf("%+v", Wrap(thirdParty, "y")); strings.Count(actual, "TestWrap\n") != 1 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", Wrap(%#v, \"y\")): got %#v, expected the stack once", thirdParty, actual)
+	}
+
+	nested := Wrap(AdvancedError{Err: AttachStackToError(io.EOF, 0)}, "z")
+	if actual := fmt.Sprintf("%+v", nested); !strings.HasPrefix(actual, "z\nEOF\n") ||
+		strings.Count(actual, "TestWrap\n") != 1 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected the nested stack completely", nested, actual)
+	}
+
+	jsn, err := json.Marshal(outer)
+	if err != nil {
+		t.Fatalf("json.Marshal(%#v): got %#v, expected nil", outer, err)
+	}
+
+	if !strings.HasPrefix(string(jsn), `{"error":{"message":"loading config","error":{"message":"reading","error":"EOF"}},`) {
+		t.Errorf("json.Marshal(%#v): got %#v, expected the message chain", outer, string(jsn))
+	}
+
+	var re RemoteError
+	if err := json.Unmarshal(jsn, &re); err != nil {
+		t.Errorf("RemoteError#UnmarshalJSON(%#v): got %#v, expected nil", string(jsn), err)
+	} else {
+		if actual := fmt.Sprintf("%+v", re); actual != expected {
+			t.Errorf("fmt.Sprintf(\"%%+v\", RemoteError): got %#v, expected %#v", actual, expected)
+		}
+
+		if actual, err := json.Marshal(re); err != nil || string(actual) != string(jsn) {
+			t.Errorf("json.Marshal(RemoteError): got %#v, %#v, expected %#v, nil", string(actual), err, string(jsn))
+		}
+	}
+}
+
+func TestWrap_OwnStack(t *testing.T) {
+	attempts := MultiError{AttachStackToError(io.EOF, 0), AttachStackToError(io.ErrUnexpectedEOF, 0)}
+
+	for _, c := range []struct {
+		err    ErrorWithStack
+		stacks int
+	}{
+		{attempts, 2},
+		{RetryError{attempts, ErrMaxAttempts}, 2},
+		{RepeatedError{attempts[0], 2, time.Now()}, 1},
+		{CircuitOpenError{attempts[0], attempts[1].StackTrace()}, 1},
+	} {
+		wrapped := Wrap(c.err, "x")
+		if actual := fmt.Sprintf("%+v", wrapped); !strings.HasPrefix(actual, "x\n") ||
+			strings.Count(actual, "TestWrap_OwnStack\n") != c.stacks {
+			t.Errorf("fmt.Sprintf(\"%%+v\", Wrap(%#v, \"x\")): got %#v, expected %d stack(s)", c.err, actual, c.stacks)
+		}
+	}
+}
+
+func TestGetFields(t *testing.T) {
+	inner := AttachFieldsToError(io.EOF, 0, Field{"a", 1}, Field{"b", 2})
+	outer := AttachFieldsToError(errors.WithMessage(inner, "x"), 0, Field{"a", 3})
+
+	if actual := GetFields(outer); len(actual) != 3 || actual[0] != (Field{"a", 3}) || actual[2] != (Field{"b", 2}) {
+		t.Errorf("GetFields(%#v): got %#v, expected a=3, a=1, b=2", outer, actual)
+	}
+
+	if actual, ok := LookupField(outer, "a"); !ok || actual != 3 {
+		t.Errorf("LookupField(%#v, \"a\"): got %#v, %#v, expected 3, true", outer, actual, ok)
+	}
+
+	if actual, ok := LookupField(outer, "b"); !ok || actual != 2 {
+		t.Errorf("LookupField(%#v, \"b\"): got %#v, %#v, expected 2, true", outer, actual, ok)
+	}
+
+	if actual, ok := LookupField(AttachFieldsToError(outer, 0, Field{"a", 4}), "a"); !ok || actual != 4 {
+		t.Errorf("LookupField(..., \"a\"): got %#v, %#v, expected 4, true", actual, ok)
+	}
+
+	if actual, ok := LookupField(AttachFieldsToError(io.EOF, 0, Field{"a", 1}, Field{"a", 2}), "a"); !ok || actual != 2 {
+		t.Errorf("LookupField(..., \"a\"): got %#v, %#v, expected 2, true", actual, ok)
+	}
+
+	if actual, ok := LookupField(outer, "c"); ok {
+		t.Errorf("LookupField(%#v, \"c\"): got %#v, %#v, expected nil, false", outer, actual, ok)
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	WalkError(nil, func(error, int) bool {
+		t.Error("WalkError(nil, ...): visited an error")
+		return true
+	})
+
+	inner := AttachStackToError(io.EOF, 0)
+	multi := MultiError{Wrap(inner, "a"), AttachStackToError(io.ErrClosedPipe, 0)}
+	outer := errors.WithMessage(multi, "b")
+
+	var visited []string
+	WalkError(outer, func(err error, depth int) bool {
+		visited = append(visited, fmt.Sprintf("%d:%T", depth, err))
+		return true
+	})
+
+	const expected = "[0:*errors.withMessage 1:fuel.MultiError 2:fuel.AdvancedError 3:fuel.MessageError " +
+		"4:*errors.errorString 2:fuel.AdvancedError 3:*errors.errorString]"
+
+	if actual := fmt.Sprint(visited); actual != expected {
+		t.Errorf("WalkError(%#v, ...): visited %s, expected %s", outer, actual, expected)
+	}
+
+	visited = nil
+	WalkError(outer, func(err error, depth int) bool {
+		visited = append(visited, fmt.Sprintf("%d:%T", depth, err))
+		return depth < 2
+	})
+
+	if actual := fmt.Sprint(visited); actual != "[0:*errors.withMessage 1:fuel.MultiError 2:fuel.AdvancedError]" {
+		t.Errorf("WalkError(%#v, ...): visited %s, expected the first 3 errors", outer, actual)
+	}
+
+	cycle := &cyclicError{}
+	cycle.next = &cyclicError{cycle}
+
+	var visits int
+	WalkError(cycle, func(error, int) bool {
+		visits++
+		return true
+	})
+
+	if visits != 2 {
+		t.Errorf("WalkError(%#v, ...): visited %d errors, expected 2", cycle, visits)
+	}
+
+	end := &cyclicError{}
+	visits = 0
+
+	WalkError(end, func(error, int) bool {
+		visits++
+		return true
+	})
+
+	if visits != 1 {
+		t.Errorf("WalkError(%#v, ...): visited %d errors, expected just the one with a typed nil child", end, visits)
+	}
+
+	if actual := RootCause(end); actual != end {
+		t.Errorf("RootCause(%#v): got %#v, expected itself", end, actual)
+	}
+
+	if actual := RootCause(outer); actual != io.EOF {
+		t.Errorf("RootCause(%#v): got %#v, expected io.EOF", outer, actual)
+	}
+
+	if actual := RootCause(io.EOF); actual != io.EOF {
+		t.Errorf("RootCause(io.EOF): got %#v, expected io.EOF", actual)
+	}
+
+	if actual, ok := DeepestStackTracer(outer).(AdvancedError); !ok || actual.Err.(MessageError).Err != io.EOF {
+		t.Errorf("DeepestStackTracer(%#v): got %#v, expected the first AdvancedError", outer, actual)
+	}
+
+	if actual, ok := DeepestStackTracer(errors.WithMessage(inner, "c")).(AdvancedError); !ok || actual.Err != io.EOF {
+		t.Errorf("DeepestStackTracer(...): got %#v, expected AdvancedError{Err: io.EOF}", actual)
+	}
+
+	if actual := DeepestStackTracer(io.EOF); actual != nil {
+		t.Errorf("DeepestStackTracer(io.EOF): got %#v, expected nil", actual)
+	}
+
+	var aes []AdvancedError
+	AllAs(outer, &aes)
+
+	if len(aes) != 2 || aes[0].Err.(MessageError).Err != io.EOF || aes[1].Err != io.ErrClosedPipe {
+		t.Errorf("AllAs(%#v, &[]AdvancedError): got %#v, expected 2 AdvancedErrors", outer, aes)
+	}
+
+	var sts []StackTracer
+	AllAs(outer, &sts)
+
+	if len(sts) != 3 {
+		t.Errorf("AllAs(%#v, &[]StackTracer): got %#v, expected 3 StackTracers", outer, sts)
+	}
+}
+
+type cyclicError struct {
+	next *cyclicError
+}
+
+var _ Unwrapper = (*cyclicError)(nil)
+
+func (ce *cyclicError) Error() string {
+	return "cycle"
+}
+
+func (ce *cyclicError) Unwrap() error {
+	return ce.next
+}
+
 func TestGetStack(t *testing.T) {
 	if stack := GetStack(0); len(stack) < 1 {
 		t.Error("GetStack(0): stack empty")
@@ -123,6 +411,41 @@ func TestAdvancedError_Format(t *testing.T) {
 	)
 }
 
+func TestAdvancedError_Format_Fields(t *testing.T) {
+	ae := AdvancedError{Err: FieldsError{io.EOF, []Field{{"id", 42}, {"user", "John Doe"}, {"empty", ""}}}}
+
+	if actual := fmt.Sprintf("%+v", ae); actual != "EOF\nid=42 user=\"John Doe\" empty=\"\"" {
+		t.Errorf("AdvancedError#Format(): got %#v", actual)
+	}
+
+	if actual := fmt.Sprintf("%s", ae); actual != "EOF" {
+		t.Errorf("AdvancedError#Format(): got %#v, expected \"EOF\"", actual)
+	}
+}
+
+func TestAdvancedError_Format_Stack(t *testing.T) {
+	stack := GetStack(0)
+	ae := AdvancedError{Err: io.EOF, Stack: stack}
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		expected := fmt.Sprintf(format, io.EOF)
+		if format != "%s" {
+			expected += fmt.Sprintf(format, stack)
+		}
+
+		if actual := fmt.Sprintf(format, ae); actual != expected {
+			t.Errorf("fmt.Sprintf(%#v, %#v): got %#v, expected %#v", format, ae, actual, expected)
+		}
+	}
+
+	SetStackFilter(&StackFilter{})
+	defer SetStackFilter(nil)
+
+	if actual := fmt.Sprintf("%#v", ae); !strings.Contains(actual, "[]fuel.StackFrame{") {
+		t.Errorf("fmt.Sprintf(\"%%#v\", %#v): got %#v, expected symbolized frames", ae, actual)
+	}
+}
+
 func assertAdvancedError_Format(t *testing.T, err error, fs *Formatable, verb rune, validator func([]byte) string) {
 	t.Helper()
 
@@ -271,6 +594,122 @@ func TestAdvancedError_MarshalJSON(t *testing.T) {
 	}
 }
 
+func TestMultiError(t *testing.T) {
+	me := MultiError{AttachStackToError(io.EOF, 0), AttachStackToError(io.ErrClosedPipe, 0)}
+
+	if actual := me.Error(); actual != "2 errors occurred: EOF; io: read/write on closed pipe" {
+		t.Errorf("MultiError#Error(): got %#v", actual)
+	}
+
+	if actual := (MultiError{me[0]}).Error(); actual != io.EOF.Error() {
+		t.Errorf("MultiError#Error(): got %#v, expected %#v", actual, io.EOF.Error())
+	}
+
+	if actual := me.Unwrap(); len(actual) != 2 || actual[0].Error() != io.EOF.Error() ||
+		actual[1].Error() != io.ErrClosedPipe.Error() {
+		t.Errorf("MultiError#Unwrap(): got %#v, expected %#v", actual, me)
+	}
+
+	if actual := len(me.StackTrace()); actual != len(me[0].StackTrace()) {
+		t.Errorf("MultiError#StackTrace(): got %d frames, expected %d", actual, len(me[0].StackTrace()))
+	}
+
+	if actual := (MultiError{}).StackTrace(); actual != nil {
+		t.Errorf("MultiError{}.StackTrace(): got %#v, expected nil", actual)
+	}
+
+	text := fmt.Sprintf("%+v", me)
+	if !strings.HasPrefix(text, "2 errors occurred:\n\n#1: EOF\n") || !strings.Contains(text, "\n\n#2: io: ") {
+		t.Errorf("MultiError#Format(): got %#v", text)
+	}
+
+	if lines := strings.Count(text, "\n"); lines < 2*len(me[0].StackTrace()) {
+		t.Errorf("MultiError#Format(): got %d lines, expected >=%d", lines, 2*len(me[0].StackTrace()))
+	}
+
+	if jsn, err := me.MarshalJSON(); err == nil {
+		var tree struct {
+			Errors []struct {
+				Error string        `json:"error"`
+				Stack []interface{} `json:"stack"`
+			} `json:"errors"`
+		}
+
+		if err := json.Unmarshal(jsn, &tree); err == nil {
+			if len(tree.Errors) != 2 || tree.Errors[0].Error != io.EOF.Error() || len(tree.Errors[1].Stack) < 1 {
+				t.Errorf("MultiError#MarshalJSON(): got %#v", string(jsn))
+			}
+		} else {
+			t.Errorf("MultiError#MarshalJSON(): got bad JSON %#v: %s", string(jsn), err.Error())
+		}
+	} else {
+		t.Errorf("MultiError#MarshalJSON(): got %#v, expected nil", err)
+	}
+}
+
+func TestAdvancedError_MarshalJSON_Fields(t *testing.T) {
+	ae := AdvancedError{Err: FieldsError{io.EOF, []Field{{"id", 42}, {"user", "John Doe"}, {"id", 43}}}}
+
+	if jsn, err := ae.MarshalJSON(); err == nil {
+		const expected = `{"error":"EOF","fields":{"id":43,"user":"John Doe"}}`
+		if string(jsn) != expected {
+			t.Errorf("AdvancedError#MarshalJSON(): got %#v, expected %#v", string(jsn), expected)
+		}
+	} else {
+		t.Errorf("AdvancedError#MarshalJSON(): got %#v, expected nil", err)
+	}
+}
+
+func TestRemoteError_UnmarshalJSON(t *testing.T) {
+	original := AdvancedError{
+		Err: FieldsError{
+			AdvancedError{FieldsError{io.EOF, []Field{{"a", "x y"}}}, GetStack(0)},
+			[]Field{{"b", 1.5}, {"c", true}},
+		},
+		Stack: GetStack(0),
+	}
+
+	jsn, err := original.MarshalJSON()
+	if err != nil {
+		t.Fatalf("AdvancedError#MarshalJSON(): got %#v, expected nil", err)
+	}
+
+	var re RemoteError
+	if err := json.Unmarshal(jsn, &re); err != nil {
+		t.Fatalf("RemoteError#UnmarshalJSON(%#v): got %#v, expected nil", string(jsn), err)
+	}
+
+	if _, ok := re.Err.(RemoteError); !ok {
+		t.Errorf("RemoteError#UnmarshalJSON(%#v): got .Err %#v, expected RemoteError", string(jsn), re.Err)
+	}
+
+	if actual := re.Error(); actual != io.EOF.Error() {
+		t.Errorf("RemoteError#Error(): got %#v, expected %#v", actual, io.EOF.Error())
+	}
+
+	if actual, ok := LookupField(re, "a"); !ok || actual != "x y" {
+		t.Errorf("LookupField(RemoteError, \"a\"): got %#v, %#v, expected \"x y\", true", actual, ok)
+	}
+
+	for _, format := range []string{"%s", "%v", "%+v"} {
+		if actual, expected := fmt.Sprintf(format, re), fmt.Sprintf(format, original); actual != expected {
+			t.Errorf("fmt.Sprintf(%#v, RemoteError): got %#v, expected %#v", format, actual, expected)
+		}
+	}
+
+	if actual, err := re.MarshalJSON(); err != nil || string(actual) != string(jsn) {
+		t.Errorf("RemoteError#MarshalJSON(): got %#v, %#v, expected %#v, nil", string(actual), err, string(jsn))
+	}
+
+	if err := json.Unmarshal([]byte(`{"error":1e42}`), &re); err != nil || re.Message != "1e42" || re.Err != nil {
+		t.Errorf("RemoteError#UnmarshalJSON(`{\"error\":1e42}`): got %#v, %#v", re, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"error":`), &re); err == nil {
+		t.Error("RemoteError#UnmarshalJSON(`{\"error\":`): got nil, expected an error")
+	}
+}
+
 func TestErrorGroup(t *testing.T) {
 	const items = 16
 
@@ -399,6 +838,179 @@ func TestErrorGroup(t *testing.T) {
 	}
 }
 
+func TestCollectingErrorGroup(t *testing.T) {
+	{
+		eg := NewCollectingErrorGroup(context.Background(), 2, 0)
+
+		for i := 0; i < 8; i++ {
+			var err error
+			if i%2 == 0 {
+				err = io.EOF
+			}
+
+			eg.Go(1, errorGroupify(dumbSleeper(time.Second/10), err))
+		}
+
+		actual := eg.Wait()
+		if me, ok := actual.(MultiError); !ok || len(me) != 4 {
+			t.Errorf("ErrorGroup#Wait(): got %#v, expected MultiError with 4 errors", actual)
+		} else {
+			for _, err := range me {
+				if ae, ok := err.(AdvancedError); !ok || ae.Err != io.EOF {
+					t.Errorf("ErrorGroup#Wait(): got %#v, expected AdvancedError{Err: io.EOF}", err)
+				}
+			}
+		}
+	}
+
+	assertTakesTime(t, 2*time.Second/10, time.Second/10, func() {
+		eg := NewCollectingErrorGroup(context.Background(), 1, 2)
+
+		for i := 0; i < 8; i++ {
+			eg.Go(1, errorGroupify(dumbSleeper(time.Second/10), io.EOF))
+		}
+
+		if actual := eg.Wait(); len(actual.(MultiError)) != 2 {
+			t.Errorf("ErrorGroup#Wait(): got %#v, expected MultiError with 2 errors", actual)
+		}
+	})
+
+	if actual := NewCollectingErrorGroup(context.Background(), 0, 0).Wait(); actual != nil {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected nil", actual)
+	}
+}
+
+func TestErrorGroup_Segments(t *testing.T) {
+	outer := NewErrorGroup(context.Background(), 0)
+
+	outer.Go(1, func(ctx context.Context) ErrorWithStack {
+		inner := NewErrorGroup(ctx, 0)
+		inner.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+		return inner.Wait()
+	})
+
+	err := outer.Wait()
+	ae, ok := err.(AdvancedError)
+	frames := ae.StackFrames()
+	segments := ae.StackSegments()
+
+	if !ok || len(segments) != 2 || segments[0].CreatedBy != "ErrorGroup.Go" ||
+		segments[0].Start >= segments[1].Start || segments[1].Start >= len(frames) {
+		t.Fatalf("ErrorGroup#Wait(): got %#v, expected AdvancedError with 2 segments", err)
+	}
+
+	text := fmt.Sprintf("%+v", ae)
+	if actual := strings.Count(text, "\ncreated by ErrorGroup.Go at errors.go:"); actual != 2 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected 2 goroutine separators", ae, text)
+	}
+
+	if actual := fmt.Sprintf("%v", ae); strings.Contains(actual, "created by") {
+		t.Errorf("fmt.Sprintf(\"%%v\", %#v): got %#v, expected no goroutine separators", ae, actual)
+	}
+
+	jsn, errJM := ae.MarshalJSON()
+	if errJM != nil {
+		t.Fatalf("AdvancedError#MarshalJSON(): got %#v, expected nil", errJM)
+	}
+
+	var doc struct {
+		Stack    []interface{}  `json:"stack"`
+		Segments []StackSegment `json:"segments"`
+	}
+
+	if err := json.Unmarshal(jsn, &doc); err != nil || len(doc.Stack) != len(frames) ||
+		fmt.Sprint(doc.Segments) != fmt.Sprint(segments) {
+		t.Errorf("AdvancedError#MarshalJSON(): got %#v, expected the whole stack and 2 segments", string(jsn))
+	}
+
+	var re RemoteError
+	if err := json.Unmarshal(jsn, &re); err != nil {
+		t.Errorf("RemoteError#UnmarshalJSON(%#v): got %#v, expected nil", string(jsn), err)
+	} else if actual := fmt.Sprintf("%+v", re); actual != text {
+		t.Errorf("fmt.Sprintf(\"%%+v\", RemoteError): got %#v, expected %#v", actual, text)
+	}
+}
+
+func TestErrorGroup_Segments_Cut(t *testing.T) {
+	SetStackCapture(StackCapture{Mode: CaptureShallow, Depth: 2})
+
+	outer := NewErrorGroup(context.Background(), 0)
+	outer.Go(1, func(ctx context.Context) ErrorWithStack {
+		inner := NewErrorGroup(ctx, 0)
+		inner.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+		return inner.Wait()
+	})
+
+	err := outer.Wait()
+	SetStackCapture(StackCapture{})
+
+	if ae, ok := err.(AdvancedError); !ok || len(ae.StackSegments()) != 2 {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected AdvancedError with 2 segments despite CaptureShallow", err)
+	}
+
+	eg := NewErrorGroup(context.Background(), 0)
+	eg.Go(1, func(context.Context) ErrorWithStack {
+		var err error
+		recurse(40, func() { err = errors.New("deep") })
+		return AttachStackToError(err, 0)
+	})
+
+	err = eg.Wait()
+	if actual := fmt.Sprintf("%+v", err); strings.Count(actual, "\ncreated by ErrorGroup.Go at errors.go:") != 1 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected a goroutine separator after 32 frames", err, actual)
+	}
+}
+
+func TestErrorGroup_Panic(t *testing.T) {
+	eg := NewErrorGroup(context.Background(), 1)
+	eg.Go(1, func(context.Context) ErrorWithStack { panic(io.EOF) })
+
+	err := eg.Wait()
+	if ae, ok := err.(AdvancedError); ok {
+		if pe, ok := ae.Err.(PanicError); !ok || pe.Value != io.EOF || pe.Unwrap() != io.EOF {
+			t.Errorf("ErrorGroup#Wait(): got %#v, expected PanicError{io.EOF}", ae.Err)
+		}
+
+		if text := fmt.Sprintf("%+v", ae); !strings.HasPrefix(text, "panic: EOF\n") ||
+			!strings.Contains(text, "TestErrorGroup_Panic.func1\n") ||
+			!strings.Contains(text, "\ncreated by ErrorGroup.Go at errors.go:") {
+			t.Errorf("ErrorGroup#Wait(): got %s, expected the stacks of the panic and of ErrorGroup#Go()", text)
+		}
+	} else {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected AdvancedError", err)
+	}
+
+	eg = NewCollectingErrorGroup(context.Background(), 0, 0)
+	eg.RePanic = true
+
+	eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+	eg.Go(1, func(context.Context) ErrorWithStack { panic(42) })
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("ErrorGroup#Wait(): didn't panic")
+			} else if ae, ok := r.(AdvancedError); !ok || ae.Err != (PanicError{42}) {
+				t.Errorf("ErrorGroup#Wait(): panicked with %#v, expected AdvancedError{Err: PanicError{42}}", r)
+			}
+		}()
+
+		eg.Wait()
+	}()
+
+	for name, task := range map[string]func(context.Context) ErrorWithStack{
+		"panic(nil)":       func(context.Context) ErrorWithStack { panic(nil) },
+		"runtime.Goexit()": func(context.Context) ErrorWithStack { runtime.Goexit(); return nil },
+	} {
+		eg = NewErrorGroup(context.Background(), 0)
+		eg.Go(1, task)
+
+		if ae, ok := eg.Wait().(AdvancedError); !ok || ae.Err != (PanicError{}) {
+			t.Errorf("ErrorGroup#Wait() after %s: got %#v, expected AdvancedError{Err: PanicError{}}", name, ae)
+		}
+	}
+}
+
 func recurse(steps uint8, finally func()) {
 	if steps > 0 {
 		recurse(steps-1, finally)
@@ -0,0 +1,63 @@
+package fuel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sync/atomic"
+)
+
+// FingerprintOptions specify which parts of an error Fingerprint() considers.
+type FingerprintOptions struct {
+	// Frames limits the amount of frames considered from the top. < 1 means all.
+	Frames int
+	// Filter, if not nil, drops frames before they're counted, e.g. the runtime ones.
+	Filter *StackFilter
+}
+
+// Fingerprint returns a stable hash of the type of $err's root cause and the frames of its outermost stack,
+// normalized to function and file name. So it's the same for all occurrences of the same failure,
+// independent of line numbers, messages and the machine the binary has been built on.
+func Fingerprint(err error, opts FingerprintOptions) string {
+	if err == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\n", RootCause(err))
+
+	if st := outermostStackTracer(err); st != nil {
+		frames := opts.Filter.Apply(FramesOf(st))
+		if opts.Frames > 0 && len(frames) > opts.Frames {
+			frames = frames[:opts.Frames]
+		}
+
+		for _, frame := range frames {
+			fmt.Fprintf(h, "%s %s\n", frame.Function, path.Base(frame.File))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+var jsonFingerprint atomic.Value
+
+func init() {
+	jsonFingerprint.Store((*FingerprintOptions)(nil))
+}
+
+// SetJSONFingerprint makes AdvancedError#MarshalJSON() and RemoteError#MarshalJSON() include a "fingerprint"
+// as by Fingerprint() with $opts. nil (the default) disables that.
+func SetJSONFingerprint(opts *FingerprintOptions) {
+	jsonFingerprint.Store(opts)
+}
+
+// jsonFingerprintOf returns the "fingerprint" of the JSON representation of $err, if enabled.
+func jsonFingerprintOf(err error) string {
+	if opts := jsonFingerprint.Load().(*FingerprintOptions); opts != nil {
+		return Fingerprint(err, *opts)
+	}
+
+	return ""
+}
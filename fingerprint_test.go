@@ -0,0 +1,65 @@
+package fuel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	if actual := Fingerprint(nil, FingerprintOptions{}); actual != "" {
+		t.Errorf("Fingerprint(nil, ...): got %#v, expected \"\"", actual)
+	}
+
+	var same [2]ErrorWithStack
+	for i := range same {
+		same[i] = Wrapf(failingFunction(), "attempt %d", i)
+	}
+
+	other := AttachStackToError(io.EOF, 0)
+	otherType := AttachStackToError(context.DeadlineExceeded, 0)
+
+	assertFingerprint(t, same[0], same[1], FingerprintOptions{}, true)
+	assertFingerprint(t, same[0], other, FingerprintOptions{}, false)
+	assertFingerprint(t, same[0], other, FingerprintOptions{Frames: 1}, false)
+	assertFingerprint(t, same[0], other, FingerprintOptions{Frames: 2}, false)
+	testingOnly := FingerprintOptions{Filter: &StackFilter{Prefixes: []string{"testing."}}}
+	assertFingerprint(t, same[0], other, testingOnly, true)
+	assertFingerprint(t, other, otherType, testingOnly, false)
+
+	if actual := Fingerprint(same[0], FingerprintOptions{}); len(actual) != 32 {
+		t.Errorf("Fingerprint(%#v, ...): got %#v, expected 32 hex digits", same[0], actual)
+	}
+}
+
+func assertFingerprint(t *testing.T, err1, err2 error, opts FingerprintOptions, equal bool) {
+	t.Helper()
+
+	if fp1, fp2 := Fingerprint(err1, opts), Fingerprint(err2, opts); (fp1 == fp2) != equal {
+		t.Errorf("Fingerprint(%#v, %#v) vs. Fingerprint(%#v, %#v): got %#v vs. %#v", err1, opts, err2, opts, fp1, fp2)
+	}
+}
+
+func failingFunction() ErrorWithStack {
+	return AttachStackToError(io.EOF, 0)
+}
+
+func TestSetJSONFingerprint(t *testing.T) {
+	err := AttachStackToError(io.EOF, 0)
+
+	if jsn, _ := json.Marshal(err); strings.Contains(string(jsn), `"fingerprint"`) {
+		t.Errorf("json.Marshal(%#v): got %#v, expected no fingerprint", err, string(jsn))
+	}
+
+	SetJSONFingerprint(&FingerprintOptions{Frames: 3})
+	defer SetJSONFingerprint(nil)
+
+	jsn, _ := json.Marshal(err)
+	expected := `"fingerprint":"` + Fingerprint(err, FingerprintOptions{Frames: 3}) + `"}`
+
+	if !strings.HasSuffix(string(jsn), expected) {
+		t.Errorf("json.Marshal(%#v): got %#v, expected %#v at the end", err, string(jsn), expected)
+	}
+}
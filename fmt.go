@@ -63,16 +63,31 @@ var _ fmt.State = (*Formatable)(nil)
 
 func (f *Formatable) Write(b []byte) (n int, err error) {
 	n, err = f.Output.Write(b)
-	if err != nil {
-		ws := AttachStackToError(err, 0)
-		err = ws
+	return n, f.noteError(err)
+}
 
-		if f.Error == nil {
-			f.Error = ws
-		}
+// WriteString is like Write, but spares io.WriteString() copying $s if f.Output is an io.StringWriter.
+func (f *Formatable) WriteString(s string) (n int, err error) {
+	n, err = io.WriteString(f.Output, s)
+	return n, f.noteError(err)
+}
+
+// noteError records $err, if any, as f.Error unless already set.
+func (f *Formatable) noteError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	ws := AttachStackToError(
+		err,
+		1, // Write or WriteString
+	)
+
+	if f.Error == nil {
+		f.Error = ws
 	}
 
-	return
+	return ws
 }
 
 func (f *Formatable) Width() (int, bool) {
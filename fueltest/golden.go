@@ -0,0 +1,111 @@
+// Package fueltest provides helpers for testing errors of the package fuel, e.g. against golden files.
+package fueltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Al2Klimov/FUeL.go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateGoldenEnv names the environment variable which makes the golden-file helpers (over)write
+// the golden files instead of comparing them if set to a non-empty value.
+const UpdateGoldenEnv = "FUEL_UPDATE_GOLDEN"
+
+// AssertGoldenText compares the %+v representation of $err, normalized via fuel.NormalizedStackFilter(),
+// with the content of the file $golden. It reports a mismatch to $t and returns whether there was none.
+func AssertGoldenText(t testing.TB, golden string, err error) bool {
+	t.Helper()
+	return assertGolden(t, golden, []byte(fmt.Sprintf("%+v", normalizeError(err))))
+}
+
+// AssertGoldenJSON is like AssertGoldenText, but compares the indented JSON representation of $err.
+func AssertGoldenJSON(t testing.TB, golden string, err error) bool {
+	t.Helper()
+
+	actual, errJS := json.MarshalIndent(normalizeError(err), "", "\t")
+	if errJS != nil {
+		t.Errorf("json.MarshalIndent(%#v): %s", err, errJS.Error())
+		return false
+	}
+
+	return assertGolden(t, golden, append(actual, '\n'))
+}
+
+func assertGolden(t testing.TB, golden string, actual []byte) bool {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Errorf("%s", err.Error())
+			return false
+		}
+
+		if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+			t.Errorf("%s", err.Error())
+			return false
+		}
+
+		return true
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Errorf("%s (set %s=1 to create it)", err.Error(), UpdateGoldenEnv)
+		return false
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s: got %q, expected %q (set %s=1 to update it)", golden, actual, expected, UpdateGoldenEnv)
+		return false
+	}
+
+	return true
+}
+
+// normalizeError returns a copy of $err which renders its stacks as by fuel.NormalizedStackFilter()
+// on top of the effective fuel.StackFilter. Only fuel.AdvancedError, fuel.RemoteError, fuel.FilteredError
+// and fuel.MultiError are normalized.
+func normalizeError(err error) error {
+	switch e := err.(type) {
+	case fuel.AdvancedError, fuel.RemoteError:
+		return fuel.FilteredError{Err: e.(fuel.ErrorWithStack), Filter: normalizeStackFilter(nil)}
+	case fuel.FilteredError:
+		e.Filter = normalizeStackFilter(e.Filter)
+		return e
+	case fuel.MultiError:
+		normalized := make(fuel.MultiError, 0, len(e))
+		for _, err := range e {
+			if ws, ok := normalizeError(err).(fuel.ErrorWithStack); ok {
+				normalized = append(normalized, ws)
+			} else {
+				normalized = append(normalized, err)
+			}
+		}
+
+		return normalized
+	default:
+		return err
+	}
+}
+
+// normalizeStackFilter returns a copy of $filter (default: fuel.GetStackFilter()) as by fuel.NormalizedStackFilter().
+func normalizeStackFilter(filter *fuel.StackFilter) *fuel.StackFilter {
+	if filter == nil {
+		filter = fuel.GetStackFilter()
+	}
+
+	normalized := fuel.NormalizedStackFilter()
+	if filter != nil {
+		*normalized = *filter
+		normalized.DropRuntime = true
+		normalized.TrimPaths = true
+		normalized.MaskLines = true
+	}
+
+	return normalized
+}
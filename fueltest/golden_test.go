@@ -0,0 +1,75 @@
+package fueltest
+
+import (
+	"fmt"
+	"github.com/Al2Klimov/FUeL.go"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertGoldenText(t *testing.T) {
+	err := fuel.Wrap(fuel.AttachFieldsToError(io.EOF, 0, fuel.Field{Key: "user", Value: "alice"}), "reading config")
+
+	AssertGoldenText(t, filepath.Join("testdata", "TestAssertGoldenText.txt"), err)
+	AssertGoldenText(t, filepath.Join("testdata", "TestAssertGoldenText.txt"), fuel.ParseErrorText(fmt.Sprintf("%+v", err)))
+	AssertGoldenText(t, filepath.Join("testdata", "TestAssertGoldenText_multi.txt"), fuel.MultiError{err, err})
+}
+
+func TestAssertGoldenJSON(t *testing.T) {
+	err := fuel.Wrap(fuel.AttachFieldsToError(io.EOF, 0, fuel.Field{Key: "user", Value: "alice"}), "reading config")
+
+	AssertGoldenJSON(t, filepath.Join("testdata", "TestAssertGoldenJSON.json"), err)
+}
+
+func TestAssertGolden_update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	golden := filepath.Join(dir, "sub", "golden.txt")
+	recorder := &testingRecorder{TB: t}
+
+	if AssertGoldenText(recorder, golden, io.EOF) || len(recorder.errors) != 1 ||
+		!strings.Contains(recorder.errors[0], UpdateGoldenEnv) {
+		t.Errorf("AssertGoldenText(..., %#v, io.EOF): got %#v, expected a missing file", golden, recorder.errors)
+	}
+
+	os.Setenv(UpdateGoldenEnv, "1")
+	ok := AssertGoldenText(recorder, golden, io.EOF)
+	os.Unsetenv(UpdateGoldenEnv)
+
+	if content, _ := ioutil.ReadFile(golden); !ok || string(content) != "EOF" {
+		t.Errorf("AssertGoldenText(..., %#v, io.EOF): wrote %#v, expected \"EOF\"", golden, string(content))
+	}
+
+	recorder.errors = nil
+	if !AssertGoldenText(recorder, golden, io.EOF) || len(recorder.errors) != 0 {
+		t.Errorf("AssertGoldenText(..., %#v, io.EOF): got %#v, expected a match", golden, recorder.errors)
+	}
+
+	if AssertGoldenText(recorder, golden, io.ErrUnexpectedEOF) || len(recorder.errors) != 1 {
+		t.Errorf(
+			"AssertGoldenText(..., %#v, io.ErrUnexpectedEOF): got %#v, expected a mismatch", golden, recorder.errors,
+		)
+	}
+}
+
+// testingRecorder records the errors reported to it instead of failing the test.
+type testingRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (*testingRecorder) Helper() {
+}
+
+func (tr *testingRecorder) Errorf(format string, args ...interface{}) {
+	tr.errors = append(tr.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,109 @@
+package fuel
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// NormalizedStackFilter returns a StackFilter rendering stacks the same way on every machine,
+// e.g. for golden-file tests: without runtime frames, with relative paths and masked line numbers.
+func NormalizedStackFilter() *StackFilter {
+	return &StackFilter{DropRuntime: true, TrimPaths: true, MaskLines: true}
+}
+
+var (
+	goRootSrcPath   string
+	goRootSrcOnce   sync.Once
+	goPathRoots     []string
+	goPathRootsOnce sync.Once
+	moduleRoots     sync.Map
+)
+
+// goRootSrc returns GOROOT/src with a trailing slash as compiled into the standard library's frames,
+// "" if the binary doesn't tell (e.g. built with -trimpath).
+func goRootSrc() string {
+	goRootSrcOnce.Do(func() {
+		pc := reflect.ValueOf(runtime.Gosched).Pointer()
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			file, _ := fn.FileLine(pc)
+			if suffix := "/runtime/" + path.Base(file); strings.HasSuffix(file, suffix) {
+				goRootSrcPath = file[:len(file)-len(suffix)+1]
+			}
+		}
+	})
+
+	return goRootSrcPath
+}
+
+// trimPath makes $file relative to GOROOT/src, a GOPATH's pkg/mod or src or the module root containing it.
+func trimPath(file string) string {
+	goPathRootsOnce.Do(func() {
+		var roots []string
+		if goRoot := goRootSrc(); goRoot != "" {
+			roots = append(roots, goRoot)
+		}
+
+		goPath := os.Getenv("GOPATH")
+		if goPath == "" {
+			if home := userHomeDir(); home != "" {
+				goPath = filepath.Join(home, "go")
+			}
+		}
+
+		for _, root := range filepath.SplitList(goPath) {
+			if root != "" {
+				root = filepath.ToSlash(root)
+				roots = append(roots, path.Join(root, "pkg", "mod")+"/", path.Join(root, "src")+"/")
+			}
+		}
+
+		goPathRoots = roots
+	})
+
+	for _, root := range goPathRoots {
+		if strings.HasPrefix(file, root) {
+			return file[len(root):]
+		}
+	}
+
+	if root := moduleRootOf(path.Dir(file)); root != "" {
+		return strings.TrimPrefix(file, root+"/")
+	}
+
+	return file
+}
+
+// moduleRootOf returns the nearest directory containing a go.mod file starting with $dir, "" if none.
+// The results are cached process-wide.
+func moduleRootOf(dir string) string {
+	if cached, ok := moduleRoots.Load(dir); ok {
+		return cached.(string)
+	}
+
+	var root string
+	if _, err := os.Stat(dir + "/go.mod"); err == nil {
+		root = dir
+	} else if parent := path.Dir(dir); parent != dir && parent != "." {
+		root = moduleRootOf(parent)
+	}
+
+	moduleRoots.Store(dir, root)
+	return root
+}
+
+// userHomeDir returns the current user's home directory like os.UserHomeDir() which requires Go 1.12.
+func userHomeDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("USERPROFILE")
+	case "plan9":
+		return os.Getenv("home")
+	default:
+		return os.Getenv("HOME")
+	}
+}
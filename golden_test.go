@@ -0,0 +1,32 @@
+package fuel
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestStackFilter_Apply_Normalized(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	frames := []StackFrame{
+		{File: file, Line: line, Function: "github.com/Al2Klimov/FUeL.go.TestStackFilter_Apply_Normalized"},
+		{File: goRootSrc() + "testing/testing.go", Line: 42},
+		{File: "/nonexistent/x.go", Line: 23, Function: "main.main"},
+		{File: "/nonexistent/y.s", Line: 5, Function: "runtime.goexit"},
+	}
+
+	actual := NormalizedStackFilter().Apply(frames)
+	expected := []StackFrame{
+		{File: "golden_test.go", Function: frames[0].Function},
+		{File: "testing/testing.go"},
+		{File: "/nonexistent/x.go", Function: "main.main"},
+	}
+
+	if fmt.Sprintf("%#v", actual) != fmt.Sprintf("%#v", expected) {
+		t.Errorf("NormalizedStackFilter().Apply(%#v): got %#v, expected %#v", frames, actual, expected)
+	}
+
+	if frames[0].File != file || frames[0].Line != line {
+		t.Errorf("NormalizedStackFilter().Apply(...): modified the input frames")
+	}
+}
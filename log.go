@@ -0,0 +1,165 @@
+package fuel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrorRenderer renders any error (chain) as a document for a log pipeline.
+type ErrorRenderer interface {
+	RenderError(err error) ([]byte, error)
+}
+
+// LogfmtRenderer renders errors as logfmt lines (without trailing newline) with the keys
+// error.message, error.type, error.stack_trace and the ones of the fields (see GetFields).
+// Each field key is rendered once, with the newest value like by MarshalJSON() (see LookupField).
+type LogfmtRenderer struct {
+}
+
+var _ ErrorRenderer = LogfmtRenderer{}
+
+func (LogfmtRenderer) RenderError(err error) ([]byte, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	buf := &bytes.Buffer{}
+	r := GetRedactor()
+	pairs := []Field{{"error.message", r.Redact(err.Error())}, {"error.type", errorType(err)}}
+
+	if stack := stackTraceText(err); stack != "" {
+		pairs = append(pairs, Field{"error.stack_trace", stack})
+	}
+
+	newest := fieldsMapOf(err)
+	for _, field := range GetFields(err) {
+		if value, ok := newest[field.Key]; ok {
+			pairs = append(pairs, Field{field.Key, value})
+			delete(newest, field.Key)
+		}
+	}
+
+	for i, pair := range pairs {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(pair.Key)
+		buf.WriteByte('=')
+		buf.WriteString(quoteFieldValue(fmt.Sprintf("%+v", pair.Value)))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ECSRenderer renders errors as Elastic Common Schema JSON documents of the current time.
+// The fields (see GetFields) become labels.
+type ECSRenderer struct {
+}
+
+// ecsVersion is the ECS version ECSRenderer complies with.
+const ecsVersion = "8.11.0"
+
+var _ ErrorRenderer = ECSRenderer{}
+
+func (ECSRenderer) RenderError(err error) ([]byte, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	type ecsError struct {
+		Message    string `json:"message"`
+		Type       string `json:"type"`
+		StackTrace string `json:"stack_trace,omitempty"`
+	}
+
+	type ecs struct {
+		Version string `json:"version"`
+	}
+
+	var labels map[string]string
+	if fields := fieldsMapOf(err); len(fields) > 0 {
+		labels = make(map[string]string, len(fields))
+		for k, v := range fields {
+			labels[k] = fmt.Sprintf("%+v", v)
+		}
+	}
+
+	message := GetRedactor().Redact(err.Error())
+
+	return json.Marshal(struct {
+		Timestamp string            `json:"@timestamp"`
+		ECS       ecs               `json:"ecs"`
+		Message   string            `json:"message"`
+		Error     ecsError          `json:"error"`
+		Labels    map[string]string `json:"labels,omitempty"`
+	}{
+		time.Now().UTC().Format(time.RFC3339Nano), ecs{ecsVersion},
+		message, ecsError{message, errorType(err), stackTraceText(err)}, labels,
+	})
+}
+
+// GELFRenderer renders errors as Graylog Extended Log Format 1.1 JSON documents with level 3 (error).
+// The fields (see GetFields) become additional fields.
+type GELFRenderer struct {
+	// Host is the GELF host. Defaults to os.Hostname().
+	Host string
+}
+
+var _ ErrorRenderer = GELFRenderer{}
+
+var gelfInvalidKeyChars = regexp.MustCompile(`[^\w.\-]`)
+
+func (gr GELFRenderer) RenderError(err error) ([]byte, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	host := gr.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	doc := map[string]interface{}{}
+	for k, v := range fieldsMapOf(err) {
+		if k = gelfInvalidKeyChars.ReplaceAllString(k, "_"); k != "id" {
+			doc["_"+k] = v
+		}
+	}
+
+	doc["version"] = "1.1"
+	doc["host"] = host
+	doc["short_message"] = GetRedactor().Redact(err.Error())
+	doc["full_message"] = GetRedactor().Redact(fmt.Sprintf("%+v", err))
+	doc["level"] = 3
+	doc["_error_type"] = errorType(err)
+
+	if stack := stackTraceText(err); stack != "" {
+		doc["_stack_trace"] = stack
+	}
+
+	return json.Marshal(doc)
+}
+
+// errorType returns the Go type of the root cause of $err.
+func errorType(err error) string {
+	return fmt.Sprintf("%T", RootCause(err))
+}
+
+// stackTraceText renders the outermost stack of $err as by %+v, but without leading newline.
+func stackTraceText(err error) string {
+	st := outermostStackTracer(err)
+	if st == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	formatSegments(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v', renderedSegmentsOf(st))
+
+	return strings.TrimPrefix(buf.String(), "\n")
+}
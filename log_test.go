@@ -0,0 +1,116 @@
+package fuel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtRenderer_RenderError(t *testing.T) {
+	if actual, err := (LogfmtRenderer{}).RenderError(nil); actual != nil || err != nil {
+		t.Errorf("LogfmtRenderer#RenderError(nil): got %#v, %#v, expected nil, nil", actual, err)
+	}
+
+	err := AttachFieldsToError(Wrap(io.EOF, "reading"), 0, Field{"user", "John Doe"}, Field{"id", 42})
+	actual, errRE := LogfmtRenderer{}.RenderError(err)
+
+	if errRE != nil {
+		t.Fatalf("LogfmtRenderer#RenderError(%#v): got error %#v, expected nil", err, errRE)
+	}
+
+	const prefix = `error.message="reading: EOF" error.type=*errors.errorString error.stack_trace="` +
+		`github.com/Al2Klimov/FUeL%2ego.TestLogfmtRenderer_RenderError\n\t`
+
+	if line := string(actual); !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, `" user="John Doe" id=42`) ||
+		strings.Contains(line, "\n") {
+		t.Errorf("LogfmtRenderer#RenderError(%#v): got %#v", err, line)
+	}
+
+	if actual, _ := (LogfmtRenderer{}).RenderError(io.EOF); string(actual) != "error.message=EOF error.type=*errors.errorString" {
+		t.Errorf("LogfmtRenderer#RenderError(io.EOF): got %#v", string(actual))
+	}
+
+	err = AttachFieldsToError(err, 0, Field{"id", 43}, Field{"id", 44})
+	if actual, _ := (LogfmtRenderer{}).RenderError(err); !strings.HasSuffix(string(actual), `" user="John Doe" id=44`) {
+		t.Errorf("LogfmtRenderer#RenderError(%#v): got %#v, expected the newest id once", err, string(actual))
+	}
+}
+
+func TestECSRenderer_RenderError(t *testing.T) {
+	eg := NewErrorGroup(context.Background(), 0)
+	eg.Go(1, func(context.Context) ErrorWithStack {
+		return AttachFieldsToError(io.EOF, 0, Field{"id", 42})
+	})
+
+	err := AttachFieldsToError(eg.Wait(), 0, Field{"id", 43})
+	jsn, errRE := ECSRenderer{}.RenderError(err)
+
+	if errRE != nil {
+		t.Fatalf("ECSRenderer#RenderError(%#v): got error %#v, expected nil", err, errRE)
+	}
+
+	var doc struct {
+		Timestamp time.Time `json:"@timestamp"`
+		ECS       struct {
+			Version string `json:"version"`
+		} `json:"ecs"`
+		Message string `json:"message"`
+		Error   struct {
+			Message    string `json:"message"`
+			Type       string `json:"type"`
+			StackTrace string `json:"stack_trace"`
+		} `json:"error"`
+		Labels map[string]string `json:"labels"`
+	}
+
+	if err := json.Unmarshal(jsn, &doc); err != nil {
+		t.Fatalf("ECSRenderer#RenderError(...): got bad JSON %#v: %s", string(jsn), err.Error())
+	}
+
+	if doc.Message != "EOF" || doc.Error.Message != "EOF" || doc.Error.Type != "*errors.errorString" ||
+		!strings.Contains(doc.Error.StackTrace, "\ncreated by ErrorGroup.Go at errors.go:") || doc.Labels["id"] != "43" {
+		t.Errorf("ECSRenderer#RenderError(...): got %#v", string(jsn))
+	}
+
+	if doc.Timestamp.IsZero() || doc.ECS.Version != ecsVersion {
+		t.Errorf("ECSRenderer#RenderError(...): got %#v, expected @timestamp and ecs.version", string(jsn))
+	}
+}
+
+func TestGELFRenderer_RenderError(t *testing.T) {
+	err := AttachFieldsToError(io.EOF, 0, Field{"user name", "John"}, Field{"id", 1}, Field{"n", 2})
+	jsn, errRE := GELFRenderer{Host: "example.com"}.RenderError(err)
+
+	if errRE != nil {
+		t.Fatalf("GELFRenderer#RenderError(%#v): got error %#v, expected nil", err, errRE)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsn, &doc); err != nil {
+		t.Fatalf("GELFRenderer#RenderError(...): got bad JSON %#v: %s", string(jsn), err.Error())
+	}
+
+	for k, v := range map[string]interface{}{
+		"version": "1.1", "host": "example.com", "short_message": "EOF", "level": 3.0,
+		"_error_type": "*errors.errorString", "_user_name": "John", "_n": 2.0,
+	} {
+		if doc[k] != v {
+			t.Errorf("GELFRenderer#RenderError(...): got .%s %#v, expected %#v", k, doc[k], v)
+		}
+	}
+
+	if _, ok := doc["_id"]; ok {
+		t.Errorf("GELFRenderer#RenderError(...): got %#v, expected no _id", string(jsn))
+	}
+
+	if full, _ := doc["full_message"].(string); !strings.HasPrefix(full, "EOF\nuser name=John id=1 n=2\n") {
+		t.Errorf("GELFRenderer#RenderError(...): got .full_message %#v", full)
+	}
+
+	if stack, _ := doc["_stack_trace"].(string); !strings.HasPrefix(stack, "github.com/") {
+		t.Errorf("GELFRenderer#RenderError(...): got ._stack_trace %#v", stack)
+	}
+}
@@ -2,8 +2,12 @@ package fuel
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"syscall"
 )
 
 // SignalsToContext derives $child from $ctx and cancels it on one of $signals.
@@ -33,3 +37,107 @@ func SignalsToContext(ctx context.Context, signals ...os.Signal) (child context.
 
 	return myctx, out
 }
+
+// ExitCoder is implemented by errors which tell the exit code they shall cause, see Main.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// ExitCodeError makes Main exit with Code due to Err.
+type ExitCodeError struct {
+	Err  error
+	Code int
+}
+
+var _ error = ExitCodeError{}
+
+func (ece ExitCodeError) Error() string {
+	return ece.Err.Error()
+}
+
+var _ ExitCoder = ExitCodeError{}
+
+func (ece ExitCodeError) ExitCode() int {
+	return ece.Code
+}
+
+var _ Unwrapper = ExitCodeError{}
+
+func (ece ExitCodeError) Unwrap() error {
+	return ece.Err
+}
+
+// VerboseEnv names the environment variable which makes Main print errors with stack if set to a non-empty value.
+const VerboseEnv = "FUEL_VERBOSE"
+
+// MainOptions customize Main.
+type MainOptions struct {
+	// Verbose makes Main print errors with stack, e.g. due to a -v flag (see RegisterFlags). See also VerboseEnv.
+	Verbose bool
+}
+
+// RegisterFlags registers the flags -v and -verbose with $fs (flag.CommandLine if nil) which set mo.Verbose.
+// Call it before parsing $fs and pass *mo to Main after that.
+func (mo *MainOptions) RegisterFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	const usage = "print errors with stack"
+
+	fs.BoolVar(&mo.Verbose, "v", mo.Verbose, usage)
+	fs.BoolVar(&mo.Verbose, "verbose", mo.Verbose, usage)
+}
+
+// Main runs $f with a context cancelled on SIGINT or SIGTERM and exits the process.
+// If $f fails, its error is printed to stderr, as by %+v if verbose (see MainOptions).
+// After SIGINT/SIGTERM the exit code is 130/143, even if $f succeeds. Otherwise it's 0 if $f succeeds,
+// the one of the outermost ExitCoder its error wraps (see WalkError) or 1.
+func Main(opts MainOptions, f func(context.Context) ErrorWithStack) {
+	os.Exit(runMain(opts, f, os.Stderr))
+}
+
+// runMain does the work of Main except exiting. It returns the exit code.
+func runMain(opts MainOptions, f func(context.Context) ErrorWithStack, stderr io.Writer) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	child, reason := SignalsToContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	err := f(child)
+	cancel()
+
+	sig := <-reason
+
+	if err != nil {
+		printMainError(opts, err, stderr)
+	}
+
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+
+	if err == nil {
+		return 0
+	}
+
+	code := 1
+
+	WalkError(err, func(err error, _ int) bool {
+		if ec, ok := err.(ExitCoder); ok {
+			code = ec.ExitCode()
+			return false
+		}
+
+		return true
+	})
+
+	return code
+}
+
+// printMainError prints $err to $stderr as specified by $opts, see Main.
+func printMainError(opts MainOptions, err error, stderr io.Writer) {
+	if opts.Verbose || os.Getenv(VerboseEnv) != "" {
+		fmt.Fprintf(stderr, "%+v\n", err)
+	} else {
+		fmt.Fprintln(stderr, GetRedactor().Redact(err.Error()))
+	}
+}
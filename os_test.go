@@ -1,8 +1,13 @@
 package fuel
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"os"
+	"os/exec"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -59,3 +64,140 @@ func TestSignalsToContext(t *testing.T) {
 		t.Errorf("SignalsToContext: got %#v,%#v, expected context.Canceled,nil", ctxErr, actual)
 	}
 }
+
+func TestRunMain(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	ok := func(context.Context) ErrorWithStack { return nil }
+
+	if code := runMain(MainOptions{}, ok, stderr); code != 0 || stderr.Len() > 0 {
+		t.Errorf("runMain(...): got %d and %#v, expected 0 and nothing", code, stderr.String())
+	}
+
+	exitCode := func(context.Context) ErrorWithStack {
+		return Wrap(ExitCodeError{errors.New("usage"), 2}, "bad args")
+	}
+
+	if code := runMain(MainOptions{}, exitCode, stderr); code != 2 || stderr.String() != "bad args: usage\n" {
+		t.Errorf("runMain(...): got %d and %#v, expected 2 and \"bad args: usage\\n\"", code, stderr.String())
+	}
+
+	stderr.Reset()
+	verbose := func(context.Context) ErrorWithStack { return AttachStackToError(errors.New("boom"), 0) }
+
+	if code := runMain(MainOptions{Verbose: true}, verbose, stderr); code != 1 ||
+		!strings.HasPrefix(stderr.String(), "boom\n") || !strings.Contains(stderr.String(), "TestRunMain") {
+		t.Errorf("runMain(...): got %d and %#v, expected 1 and the stack", code, stderr.String())
+	}
+
+	stderr.Reset()
+	signaled := func(ctx context.Context) ErrorWithStack {
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		<-ctx.Done()
+
+		return AttachStackToError(ctx.Err(), 0)
+	}
+
+	if code := runMain(MainOptions{}, signaled, stderr); code != 130 || stderr.String() != "context canceled\n" {
+		t.Errorf("runMain(...): got %d and %#v, expected 130 and \"context canceled\\n\"", code, stderr.String())
+	}
+
+	stderr.Reset()
+	gracefulExit := func(ctx context.Context) ErrorWithStack {
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		<-ctx.Done()
+
+		return nil
+	}
+
+	if code := runMain(MainOptions{}, gracefulExit, stderr); code != 143 || stderr.Len() > 0 {
+		t.Errorf("runMain(...): got %d and %#v, expected 143 and nothing", code, stderr.String())
+	}
+}
+
+func TestMainOptions_RegisterFlags(t *testing.T) {
+	for _, args := range [][]string{{"-v"}, {"-verbose"}, {"--verbose=true"}} {
+		var opts MainOptions
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts.RegisterFlags(fs)
+
+		if err := fs.Parse(args); err != nil || !opts.Verbose {
+			t.Errorf("FlagSet#Parse(%#v): got %#v and %#v, expected verbose", args, err, opts)
+		}
+	}
+}
+
+// mainTestEnv names the scenario TestMainSubprocess runs Main with in a subprocess.
+const mainTestEnv = "FUEL_TEST_MAIN"
+
+func TestMainSubprocess(t *testing.T) {
+	switch os.Getenv(mainTestEnv) {
+	case "":
+	case "fail":
+		Main(MainOptions{}, func(context.Context) ErrorWithStack {
+			return AttachStackToError(errors.New("boom"), 0)
+		})
+	case "exitcode":
+		Main(MainOptions{}, func(context.Context) ErrorWithStack {
+			return AttachStackToError(ExitCodeError{errors.New("usage"), 2}, 0)
+		})
+	case "verboseflag":
+		var opts MainOptions
+		fs := flag.NewFlagSet("test", flag.ExitOnError)
+		opts.RegisterFlags(fs)
+		fs.Parse([]string{"-v"})
+
+		Main(opts, func(context.Context) ErrorWithStack {
+			return AttachStackToError(errors.New("boom"), 0)
+		})
+	case "sigterm":
+		Main(MainOptions{}, func(ctx context.Context) ErrorWithStack {
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+			<-ctx.Done()
+
+			return AttachStackToError(ctx.Err(), 0)
+		})
+	}
+
+	for _, c := range []struct {
+		scenario string
+		env      []string
+		code     int
+		stderr   string
+		verbose  bool
+	}{
+		{"fail", nil, 1, "boom\n", false},
+		{"fail", []string{VerboseEnv + "=1"}, 1, "boom\n", true},
+		{"exitcode", nil, 2, "usage\n", false},
+		{"verboseflag", nil, 1, "boom\n", true},
+		{"sigterm", nil, 143, "context canceled\n", false},
+	} {
+		stderr := &bytes.Buffer{}
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMainSubprocess$")
+		cmd.Env = append(append(os.Environ(), mainTestEnv+"="+c.scenario), c.env...)
+		cmd.Stderr = stderr
+
+		code := 0
+		if err := cmd.Run(); err != nil {
+			ee, ok := err.(*exec.ExitError)
+			if !ok {
+				t.Errorf("%s: got error %#v", c.scenario, err)
+				continue
+			}
+
+			code = ee.Sys().(syscall.WaitStatus).ExitStatus()
+		}
+
+		if code != c.code {
+			t.Errorf("%s %v: got exit code %d, expected %d", c.scenario, c.env, code, c.code)
+		}
+
+		if c.verbose {
+			text := stderr.String()
+			if !strings.HasPrefix(text, c.stderr) || !strings.Contains(text, "TestMainSubprocess") {
+				t.Errorf("%s %v: got stderr %#v, expected the stack", c.scenario, c.env, stderr.String())
+			}
+		} else if stderr.String() != c.stderr {
+			t.Errorf("%s %v: got stderr %#v, expected %#v", c.scenario, c.env, stderr.String(), c.stderr)
+		}
+	}
+}
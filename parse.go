@@ -0,0 +1,207 @@
+package fuel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Goroutine is the stack of a goroutine as printed by the Go runtime on panics and by runtime.Stack().
+type Goroutine struct {
+	ID     int
+	State  string
+	Frames []StackFrame
+	// CreatedBy is the go statement which spawned the goroutine, if known.
+	CreatedBy *StackFrame
+	// CreatorID is the ID of the goroutine which executed CreatedBy, if known (since Go 1.21).
+	CreatorID int
+}
+
+var _ Framer = Goroutine{}
+
+func (g Goroutine) StackFrames() []StackFrame {
+	return g.Frames
+}
+
+// GoroutineDump is the text a Go program prints when it crashes or runtime.Stack(buf, true) returns.
+type GoroutineDump struct {
+	// Message is everything before the first goroutine, e.g. "panic: boom".
+	Message    string
+	Goroutines []Goroutine
+}
+
+// RemoteError returns the first goroutine, usually the crashed one, as RemoteError with gd.Message.
+// The go statement which spawned it, if known, becomes a further StackSegment.
+func (gd GoroutineDump) RemoteError() RemoteError {
+	re := RemoteError{Message: gd.Message}
+
+	if len(gd.Goroutines) > 0 {
+		g := gd.Goroutines[0]
+		re.Frames = g.Frames
+
+		if g.CreatedBy != nil {
+			re.Frames = append(append([]StackFrame(nil), g.Frames...), *g.CreatedBy)
+			re.Segments = []StackSegment{{len(g.Frames), g.CreatedBy.Function}}
+		}
+	}
+
+	return re
+}
+
+var (
+	goroutineHeader   = regexp.MustCompile(`^goroutine (\d+)[^\[]*\[([^\]]*)\]:$`)
+	goroutineCreator  = regexp.MustCompile(`^created by (.+?)(?: in goroutine (\d+))?$`)
+	frameLocation     = regexp.MustCompile(`^\t(.+):(\d+)(?: .*)?$`)
+	exactLocation     = regexp.MustCompile(`^\t(.+):(\d+)$`)
+	segmentCreator    = regexp.MustCompile(`^created by (.+?)(?: at .+:\d+)?$`)
+	repeatedFrames    = regexp.MustCompile(`^\.\.\. (\d+) more frames of the same function$`)
+	elidedFramesLines = "...additional frames elided..."
+)
+
+// ParseGoroutineDump parses $text as printed by the Go runtime on panics and by runtime.Stack(buf, true).
+// Text after the last goroutine, e.g. "exit status 2", is ignored.
+func ParseGoroutineDump(text string) (GoroutineDump, ErrorWithStack) {
+	var dump GoroutineDump
+	var message []string
+	var current *Goroutine
+
+	lines := splitLines(text)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if match := goroutineHeader.FindStringSubmatch(line); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			dump.Goroutines = append(dump.Goroutines, Goroutine{ID: id, State: match[2]})
+			current = &dump.Goroutines[len(dump.Goroutines)-1]
+			continue
+		}
+
+		if current == nil {
+			if len(dump.Goroutines) < 1 {
+				message = append(message, line)
+			}
+
+			continue
+		}
+
+		if line == elidedFramesLines {
+			continue
+		}
+
+		var location []string
+		if i+1 < len(lines) {
+			location = frameLocation.FindStringSubmatch(lines[i+1])
+		}
+
+		if location == nil || strings.HasPrefix(line, "\t") {
+			// E.g. the blank line after a goroutine or "exit status 2"
+			current = nil
+			continue
+		}
+
+		frame := StackFrame{File: location[1]}
+		frame.Line, _ = strconv.Atoi(location[2])
+		i++
+
+		if match := goroutineCreator.FindStringSubmatch(line); match != nil {
+			frame.Function = match[1]
+			current.CreatedBy = &frame
+			current.CreatorID, _ = strconv.Atoi(match[2])
+		} else {
+			frame.Function = stripFrameArgs(line)
+			current.Frames = append(current.Frames, frame)
+		}
+	}
+
+	if len(dump.Goroutines) < 1 {
+		return dump, AttachStackToError(fmt.Errorf("no goroutine found in %d lines", len(lines)), 0)
+	}
+
+	dump.Message = strings.TrimSpace(strings.Join(message, "\n"))
+	return dump, nil
+}
+
+// stripFrameArgs strips the arguments, e.g. "(0x1, ...)", from the function $line of a goroutine dump.
+func stripFrameArgs(line string) string {
+	if strings.HasSuffix(line, ")") {
+		if paren := strings.LastIndex(line, "("); paren > 0 {
+			return line[:paren]
+		}
+	}
+
+	return line
+}
+
+// ParseErrorText parses $text as printed by AdvancedError#Format() on %+v.
+// Everything before the first stack frame, including fields, becomes the Message of the returned RemoteError.
+// Only the first stack is parsed, e.g. the first error of a MultiError.
+func ParseErrorText(text string) RemoteError {
+	var re RemoteError
+	lines := splitLines(text)
+
+	first := len(lines)
+	for i := 0; i+1 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "\t") && exactLocation.MatchString(lines[i+1]) {
+			first = i
+			break
+		}
+	}
+
+	re.Message = strings.Join(lines[:first], "\n")
+
+	for i := first; i < len(lines); i++ {
+		line := lines[i]
+
+		if match := repeatedFrames.FindStringSubmatch(line); match != nil && len(re.Frames) > 0 {
+			re.Frames[len(re.Frames)-1].Repeated, _ = strconv.Atoi(match[1])
+			continue
+		}
+
+		if match := segmentCreator.FindStringSubmatch(line); match != nil {
+			re.Segments = append(re.Segments, StackSegment{len(re.Frames), match[1]})
+			continue
+		}
+
+		var location []string
+		if i+1 < len(lines) {
+			location = exactLocation.FindStringSubmatch(lines[i+1])
+		}
+
+		if location == nil || strings.HasPrefix(line, "\t") {
+			if strings.HasPrefix(line, "\t") {
+				// E.g. a source code line, see SetSourceContext
+				continue
+			}
+
+			break
+		}
+
+		frame := StackFrame{Function: unknownToEmpty(line), File: unknownToEmpty(location[1])}
+		frame.Line, _ = strconv.Atoi(location[2])
+		re.Frames = append(re.Frames, frame)
+		i++
+	}
+
+	return re
+}
+
+// unknownToEmpty is the reverse of StackFrame#function() and StackFrame#file().
+func unknownToEmpty(s string) string {
+	if s == "unknown" {
+		return ""
+	}
+
+	return s
+}
+
+// splitLines splits $text into lines without line endings.
+func splitLines(text string) []string {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
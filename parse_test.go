@@ -0,0 +1,142 @@
+package fuel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const testGoroutineDump = `panic: boom
+
+goroutine 7 [running]:
+main.(*T).M(...)
+	/tmp/pd/main.go:7
+main.main.func2()
+	/tmp/pd/main.go:11 +0x25
+created by main.main in goroutine 1
+	/tmp/pd/main.go:11 +0x26
+
+goroutine 1 gp=0xc000002380 m=nil [sleep, 5 minutes]:
+time.Sleep(0x3b9aca00)
+	/usr/local/go/src/runtime/time.go:368 +0x165 fp=0xc0000a8f50 sp=0xc0000a8f18 pc=0x46f845
+...additional frames elided...
+main.main()
+	/tmp/pd/main.go:12 +0x30
+
+goroutine 6 [runnable]:
+main.main.func1()
+	/tmp/pd/main.go:10
+created by main.main
+	/tmp/pd/main.go:10 +0x1a
+exit status 2
+`
+
+func TestParseGoroutineDump(t *testing.T) {
+	dump, err := ParseGoroutineDump(testGoroutineDump)
+	if err != nil {
+		t.Fatalf("ParseGoroutineDump(%#v): got error %#v", testGoroutineDump, err)
+	}
+
+	expected := GoroutineDump{
+		Message: "panic: boom",
+		Goroutines: []Goroutine{
+			{
+				ID:    7,
+				State: "running",
+				Frames: []StackFrame{
+					{File: "/tmp/pd/main.go", Line: 7, Function: "main.(*T).M"},
+					{File: "/tmp/pd/main.go", Line: 11, Function: "main.main.func2"},
+				},
+				CreatedBy: &StackFrame{File: "/tmp/pd/main.go", Line: 11, Function: "main.main"},
+				CreatorID: 1,
+			},
+			{
+				ID:    1,
+				State: "sleep, 5 minutes",
+				Frames: []StackFrame{
+					{File: "/usr/local/go/src/runtime/time.go", Line: 368, Function: "time.Sleep"},
+					{File: "/tmp/pd/main.go", Line: 12, Function: "main.main"},
+				},
+			},
+			{
+				ID:        6,
+				State:     "runnable",
+				Frames:    []StackFrame{{File: "/tmp/pd/main.go", Line: 10, Function: "main.main.func1"}},
+				CreatedBy: &StackFrame{File: "/tmp/pd/main.go", Line: 10, Function: "main.main"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("ParseGoroutineDump(%#v): got %#v, expected %#v", testGoroutineDump, dump, expected)
+	}
+
+	re := dump.RemoteError()
+	expectedText := "panic: boom\nmain.(*T).M\n\t/tmp/pd/main.go:7\nmain.main.func2\n\t/tmp/pd/main.go:11" +
+		"\ncreated by main.main at main.go:11\nmain.main\n\t/tmp/pd/main.go:11"
+
+	if actual := fmt.Sprintf("%+v", re); actual != expectedText {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected %#v", re, actual, expectedText)
+	}
+
+	if fp := Fingerprint(re, FingerprintOptions{}); len(fp) != 32 {
+		t.Errorf("Fingerprint(%#v, ...): got %#v, expected 32 hex digits", re, fp)
+	}
+
+	if _, err := ParseGoroutineDump("panic: boom\n"); err == nil || len(err.StackTrace()) < 1 {
+		t.Errorf("ParseGoroutineDump(\"panic: boom\\n\"): got %#v, expected an error with a stack", err)
+	}
+}
+
+func TestParseErrorText(t *testing.T) {
+	eg := NewErrorGroup(context.Background(), 1)
+	eg.Go(1, func(context.Context) ErrorWithStack {
+		var err ErrorWithStack
+		recurse(3, func() { err = AttachFieldsToError(io.EOF, 0, Field{"user", "alice"}) })
+		return Wrap(err, "reading config")
+	})
+
+	err := eg.Wait()
+	SetStackFilter(&StackFilter{CollapseRecursion: true})
+	defer SetStackFilter(nil)
+
+	text := fmt.Sprintf("%+v", err)
+	re := ParseErrorText(text)
+
+	if actual := fmt.Sprintf("%+v", re); actual != text {
+		t.Errorf("fmt.Sprintf(\"%%+v\", ParseErrorText(%#v)): got %#v", text, actual)
+	}
+
+	if re.Message != "reading config\nEOF\nuser=alice" {
+		t.Errorf("ParseErrorText(%#v).Message: got %#v, expected the message and fields", text, re.Message)
+	}
+
+	if len(re.Segments) != 1 || re.Segments[0].CreatedBy != "ErrorGroup.Go" {
+		t.Errorf("ParseErrorText(%#v).Segments: got %#v, expected ErrorGroup.Go", text, re.Segments)
+	}
+
+	if len(re.Frames) < 2 || re.Frames[1].Repeated != 3 {
+		t.Errorf("ParseErrorText(%#v).Frames: got %#v, expected the recursion collapsed", text, re.Frames)
+	}
+
+	SetSourceContext(1)
+	withContext := fmt.Sprintf("%+v", err)
+	SetSourceContext(0)
+
+	if actual := fmt.Sprintf("%+v", ParseErrorText(withContext)); actual != text {
+		t.Errorf("fmt.Sprintf(\"%%+v\", ParseErrorText(%#v)): got %#v, expected %#v", withContext, actual, text)
+	}
+
+	for _, text := range []string{"", "EOF", "unknown\n\tunknown:0"} {
+		expected := RemoteError{Message: text}
+		if text == "unknown\n\tunknown:0" {
+			expected = RemoteError{Frames: []StackFrame{{}}}
+		}
+
+		if actual := ParseErrorText(text); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("ParseErrorText(%#v): got %#v, expected %#v", text, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package fuel
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Redactor specifies what to hide in rendered errors, see SetRedactor.
+type Redactor struct {
+	// Patterns' matches in messages and field values are replaced with Replacement.
+	// Of patterns with subexpressions only the first submatch is replaced, e.g. just the password of a URL.
+	Patterns []*regexp.Regexp
+	// Keys name fields (case-insensitively) whose values are replaced with Replacement completely.
+	Keys []string
+	// Replacement defaults to "[REDACTED]".
+	Replacement string
+}
+
+// Redact returns $s with all matches of r.Patterns replaced. A nil $r redacts nothing.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	for _, pattern := range r.Patterns {
+		s = replaceMatches(pattern, s, r.replacement())
+	}
+
+	return s
+}
+
+// redactField returns the $value of the field named $key as to be rendered.
+func (r *Redactor) redactField(key string, value interface{}) interface{} {
+	if r == nil {
+		return value
+	}
+
+	for _, k := range r.Keys {
+		if strings.EqualFold(k, key) {
+			return r.replacement()
+		}
+	}
+
+	var s string
+	switch v := value.(type) {
+	case Secret:
+		return v
+	case string:
+		return r.Redact(v)
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return value
+	}
+
+	// Keep e.g. JSON representations of non-strings unless they contain something to hide.
+	if redacted := r.Redact(s); redacted != s {
+		return redacted
+	}
+
+	return value
+}
+
+func (r *Redactor) replacement() string {
+	if r == nil || r.Replacement == "" {
+		return "[REDACTED]"
+	}
+
+	return r.Replacement
+}
+
+// replaceMatches replaces the matches of $pattern in $s (or their first submatches) with $replacement.
+func replaceMatches(pattern *regexp.Regexp, s, replacement string) string {
+	matches := pattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) < 1 {
+		return s
+	}
+
+	buf := &bytes.Buffer{}
+	last := 0
+
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) > 2 {
+			if match[2] < 0 {
+				continue
+			}
+
+			start, end = match[2], match[3]
+		}
+
+		buf.WriteString(s[last:start])
+		buf.WriteString(replacement)
+		last = end
+	}
+
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// CommonSecretPatterns returns Redactor#Patterns for passwords in URLs, bearer tokens and e-mail addresses.
+func CommonSecretPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^:/?#@\s]*:([^@/?#\s]+)@`),
+		regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+}
+
+var redactor atomic.Value
+
+func init() {
+	redactor.Store((*Redactor)(nil))
+}
+
+// SetRedactor sets the Redactor all errors of this package are rendered through,
+// i.e. Format(), String(), MarshalText(), MarshalJSON(), the ErrorRenderers, ErrorLogValue and NewSentryEvent.
+// Error() stays as is for programmatic use. $r must not be modified afterwards. nil disables redaction.
+func SetRedactor(r *Redactor) {
+	redactor.Store(r)
+}
+
+// GetRedactor returns the Redactor set by SetRedactor.
+func GetRedactor() *Redactor {
+	return redactor.Load().(*Redactor)
+}
+
+// formatRedacted is like FormatNonFormatter, but redacts the output via GetRedactor().
+// Errors of this package are passed through as they redact themselves.
+func formatRedacted(fs fmt.State, verb rune, v interface{}) {
+	r := GetRedactor()
+
+	if own, _ := ownError(v); own {
+		r = nil
+	}
+
+	if r == nil {
+		FormatNonFormatter(fs, verb, v)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	state := &Formatable{Output: buf, Flags: map[int]struct{}{}}
+	state.Wid, state.HasWid = fs.Width()
+	state.Prec, state.HasPrec = fs.Precision()
+
+	for _, flag := range []int{'+', '-', '#', ' ', '0'} {
+		if fs.Flag(flag) {
+			state.Flags[flag] = struct{}{}
+		}
+	}
+
+	FormatNonFormatter(state, verb, v)
+	io.WriteString(fs, r.Redact(buf.String()))
+}
+
+// redactMarshaler returns $v as to be marshaled to JSON, with all strings of its json.Marshaler
+// or encoding.TextMarshaler output redacted via GetRedactor(). Errors of this package are returned as they are
+// as they redact themselves. So are ones failing to marshal themselves, so that json.Marshal() reports that.
+func redactMarshaler(v interface{}) interface{} {
+	r := GetRedactor()
+	if own, _ := ownError(v); own || r == nil {
+		return v
+	}
+
+	switch m := v.(type) {
+	case json.Marshaler:
+		raw, err := m.MarshalJSON()
+		if err != nil {
+			return v
+		}
+
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+
+		if dec.Decode(&doc) != nil {
+			return v
+		}
+
+		return r.redactJSON(doc)
+	case encoding.TextMarshaler:
+		text, err := m.MarshalText()
+		if err != nil {
+			return v
+		}
+
+		return r.Redact(string(text))
+	default:
+		return v
+	}
+}
+
+// redactJSON redacts all strings in the decoded JSON $v in place and returns $v.
+func (r *Redactor) redactJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return r.Redact(value)
+	case []interface{}:
+		for i, item := range value {
+			value[i] = r.redactJSON(item)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = r.redactJSON(item)
+		}
+	}
+
+	return v
+}
+
+// Secret marks a field value as secret. It's always rendered as the Replacement of GetRedactor().
+type Secret struct {
+	Value interface{}
+}
+
+// SecretField returns a Field with the secret $value.
+func SecretField(key string, value interface{}) Field {
+	return Field{key, Secret{value}}
+}
+
+var _ fmt.Formatter = Secret{}
+
+func (s Secret) Format(fs fmt.State, verb rune) {
+	io.WriteString(fs, s.String())
+}
+
+var _ fmt.Stringer = Secret{}
+
+func (Secret) String() string {
+	return GetRedactor().replacement()
+}
+
+var _ encoding.TextMarshaler = Secret{}
+
+func (s Secret) MarshalText() (text []byte, err error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,196 @@
+package fuel
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	if actual := (*Redactor)(nil).Redact("a@example.com"); actual != "a@example.com" {
+		t.Errorf("(*Redactor)(nil).Redact(\"a@example.com\"): got %#v, expected \"a@example.com\"", actual)
+	}
+
+	r := &Redactor{Patterns: CommonSecretPatterns()}
+
+	for _, c := range []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"connecting to postgres://app:s3cr3t@db:5432/app failed",
+			"connecting to postgres://app:[REDACTED]@db:5432/app failed"},
+		{"GET /: Authorization: Bearer abc.DEF-123= rejected", "GET /: Authorization: Bearer [REDACTED] rejected"},
+		{"no such user: jane.doe@example.com", "no such user: [REDACTED]"},
+		{"https://example.com/ unreachable", "https://example.com/ unreachable"},
+	} {
+		if actual := r.Redact(c.input); actual != c.expected {
+			t.Errorf("%#v.Redact(%#v): got %#v, expected %#v", r, c.input, actual, c.expected)
+		}
+	}
+
+	r = &Redactor{Patterns: []*regexp.Regexp{regexp.MustCompile(`\d+`)}, Replacement: "***"}
+	if actual := r.Redact("pin 1234 and 5678"); actual != "pin *** and ***" {
+		t.Errorf("%#v.Redact(\"pin 1234 and 5678\"): got %#v, expected \"pin *** and ***\"", r, actual)
+	}
+}
+
+func TestSetRedactor(t *testing.T) {
+	err := Wrap(
+		AttachFieldsToError(
+			errors.New("login as jane@example.com failed"), 0,
+			Field{"Password", "hunter2"}, Field{"dsn", "mysql://root:toor@db/"}, SecretField("token", "t0k3n"),
+			Field{"user", "jane"},
+		),
+		"handling request of jane@example.com",
+	)
+
+	if actual := fmt.Sprintf("%+v", err); !strings.Contains(actual, "Password=hunter2") ||
+		!strings.Contains(actual, "token=[REDACTED]") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected only the secret redacted", err, actual)
+	}
+
+	SetRedactor(&Redactor{Patterns: CommonSecretPatterns(), Keys: []string{"password"}})
+	defer SetRedactor(nil)
+
+	expected := "handling request of [REDACTED]\nlogin as [REDACTED] failed\n" +
+		"Password=[REDACTED] dsn=mysql://root:[REDACTED]@db/ token=[REDACTED] user=jane\n"
+
+	if actual := fmt.Sprintf("%+v", err); !strings.HasPrefix(actual, expected) {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected it to start with %#v", err, actual, expected)
+	}
+
+	if actual := err.(fmt.Stringer).String(); !strings.HasPrefix(actual, expected) {
+		t.Errorf("%#v.String(): got %#v, expected it to start with %#v", err, actual, expected)
+	}
+
+	if actual := fmt.Sprintf("%v", MultiError{err}); strings.Contains(actual, "@example.com") {
+		t.Errorf("fmt.Sprintf(\"%%v\", MultiError{%#v}): got %#v, expected no e-mail address", err, actual)
+	}
+
+	if actual := err.Error(); !strings.Contains(actual, "jane@example.com") {
+		t.Errorf("%#v.Error(): got %#v, expected it unredacted", err, actual)
+	}
+
+	jsn, errJS := json.Marshal(err)
+	if errJS != nil {
+		t.Fatalf("json.Marshal(%#v): got error %#v", err, errJS)
+	}
+
+	for _, secret := range []string{"@example.com", "hunter2", "toor", "t0k3n"} {
+		if strings.Contains(string(jsn), secret) {
+			t.Errorf("json.Marshal(%#v): got %#v, expected no %#v", err, string(jsn), secret)
+		}
+	}
+
+	var re RemoteError
+	if errJS := json.Unmarshal(jsn, &re); errJS != nil {
+		t.Fatalf("json.Unmarshal(%#v, ...): got error %#v", string(jsn), errJS)
+	}
+
+	if actual := re.Error(); actual != "handling request of [REDACTED]: login as [REDACTED] failed" {
+		t.Errorf("json.Unmarshal(%#v, ...): got message %#v", string(jsn), actual)
+	}
+
+	for _, renderer := range []ErrorRenderer{LogfmtRenderer{}, ECSRenderer{}, GELFRenderer{Host: "localhost"}} {
+		rendered, errRE := renderer.RenderError(err)
+		if errRE != nil {
+			t.Errorf("%#v.RenderError(%#v): got error %#v", renderer, err, errRE)
+			continue
+		}
+
+		for _, secret := range []string{"@example.com", "hunter2", "toor"} {
+			if strings.Contains(string(rendered), secret) {
+				t.Errorf("%#v.RenderError(%#v): got %#v, expected no %#v", renderer, err, string(rendered), secret)
+			}
+		}
+	}
+
+	event := NewSentryEvent(err, SentryOptions{})
+	if jsn, _ := json.Marshal(event); strings.Contains(string(jsn), "@example.com") ||
+		strings.Contains(string(jsn), "hunter2") {
+		t.Errorf("json.Marshal(NewSentryEvent(%#v, ...)): got %#v, expected no secrets", err, string(jsn))
+	}
+}
+
+func TestSetRedactor_Marshaler(t *testing.T) {
+	SetRedactor(&Redactor{Patterns: CommonSecretPatterns()})
+	defer SetRedactor(nil)
+
+	for _, inner := range []error{
+		jsonMarshalerError{"login as jane@example.com failed", 42},
+		textMarshalerError("login as jane@example.com failed"),
+	} {
+		err := Wrap(AttachStackToError(inner, 0), "handling request")
+
+		jsn, errJM := json.Marshal(err)
+		if errJM != nil {
+			t.Errorf("json.Marshal(%#v): got error %#v", err, errJM)
+		} else if !strings.Contains(string(jsn), "login as [REDACTED] failed") ||
+			strings.Contains(string(jsn), "@example.com") {
+			t.Errorf("json.Marshal(%#v): got %#v, expected the e-mail address redacted", err, string(jsn))
+		}
+
+		doc, _ := json.Marshal(errorToJSONDocument(inner))
+		if strings.Contains(string(doc), "@example.com") {
+			t.Errorf("json.Marshal(errorToJSONDocument(%#v)): got %#v, expected no secrets", inner, string(doc))
+		}
+	}
+
+	coe := CircuitOpenError{AttachStackToError(errors.New("jane@example.com"), 0), nil}
+	if actual := fmt.Sprintf("%s", Wrap(coe, "x")); actual != "x: circuit open: [REDACTED]" {
+		t.Errorf("fmt.Sprintf(\"%%s\", Wrap(%#v, \"x\")): got %#v, expected it redacted", coe, actual)
+	}
+}
+
+type jsonMarshalerError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+var _ json.Marshaler = jsonMarshalerError{}
+
+func (jme jsonMarshalerError) Error() string {
+	return jme.Message
+}
+
+func (jme jsonMarshalerError) MarshalJSON() ([]byte, error) {
+	type plain jsonMarshalerError
+	return json.Marshal(plain(jme))
+}
+
+type textMarshalerError string
+
+var _ encoding.TextMarshaler = textMarshalerError("")
+
+func (tme textMarshalerError) Error() string {
+	return string(tme)
+}
+
+func (tme textMarshalerError) MarshalText() ([]byte, error) {
+	return []byte(tme), nil
+}
+
+func TestSecret(t *testing.T) {
+	secret := Secret{"hunter2"}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%d"} {
+		if actual := fmt.Sprintf(format, secret); actual != "[REDACTED]" {
+			t.Errorf("fmt.Sprintf(%#v, Secret{\"hunter2\"}): got %#v, expected \"[REDACTED]\"", format, actual)
+		}
+	}
+
+	if jsn, _ := json.Marshal(secret); string(jsn) != `"[REDACTED]"` {
+		t.Errorf("json.Marshal(Secret{\"hunter2\"}): got %#v, expected \"\\\"[REDACTED]\\\"\"", string(jsn))
+	}
+
+	SetRedactor(&Redactor{Replacement: "***"})
+	defer SetRedactor(nil)
+
+	if actual := secret.String(); actual != "***" {
+		t.Errorf("Secret{\"hunter2\"}.String(): got %#v, expected \"***\"", actual)
+	}
+}
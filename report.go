@@ -0,0 +1,324 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reporter reports errors somewhere, e.g. to a log collector.
+type Reporter interface {
+	// Report reports $err without blocking.
+	Report(err error)
+	// Flush waits for all errors Report()ed so far to be delivered or for $ctx to be done.
+	Flush(ctx context.Context) ErrorWithStack
+}
+
+// Sink delivers batches of errors, each one marshaled to JSON, see AsyncReporter.
+type Sink interface {
+	Send(ctx context.Context, batch []json.RawMessage) ErrorWithStack
+}
+
+// ReporterOptions customize NewAsyncReporter().
+type ReporterOptions struct {
+	// BufferSize is the amount of errors queued at most. Defaults to 1024.
+	BufferSize int
+	// BatchSize is the amount of errors sent at most at once. Defaults to 100.
+	BatchSize int
+	// FlushInterval is how long errors are queued at most. Defaults to 1s.
+	FlushInterval time.Duration
+	// OnError, if not nil, is called on every error of the Sink.
+	OnError func(err ErrorWithStack)
+}
+
+// AsyncReporter queues errors and sends them in batches to a Sink in the background.
+// Errors are marshaled via json.Marshal() once Report()ed, i.e. via their MarshalJSON() if any.
+type AsyncReporter struct {
+	sink    Sink
+	opts    ReporterOptions
+	queue   chan json.RawMessage
+	flushes chan reporterFlush
+	dropped uint64
+
+	close    sync.Once
+	stopCtx  context.Context
+	stopMtx  sync.RWMutex
+	stopping chan struct{}
+	closed   chan struct{}
+}
+
+// reporterFlush requests the AsyncReporter to send all queued errors and then to close done.
+type reporterFlush struct {
+	ctx  context.Context
+	done chan struct{}
+}
+
+// NewAsyncReporter creates a new AsyncReporter sending to $sink. Close it not to leak goroutines!
+func NewAsyncReporter(sink Sink, opts ReporterOptions) *AsyncReporter {
+	if opts.BufferSize < 1 {
+		opts.BufferSize = 1024
+	}
+
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 100
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	ar := &AsyncReporter{
+		sink:     sink,
+		opts:     opts,
+		queue:    make(chan json.RawMessage, opts.BufferSize),
+		flushes:  make(chan reporterFlush),
+		stopping: make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+
+	go ar.run()
+	return ar
+}
+
+var _ Reporter = (*AsyncReporter)(nil)
+
+// Report queues $err unless the queue is full, the AsyncReporter is closed (see Dropped) or $err is nil.
+func (ar *AsyncReporter) Report(err error) {
+	if err == nil {
+		return
+	}
+
+	doc, errJS := json.Marshal(errorToJSONDocument(err))
+	if errJS != nil {
+		ar.fail(AttachStackToError(errJS, 0), 1)
+		return
+	}
+
+	// Close() can't close stopping in between, so run() will drain doc from the queue.
+	ar.stopMtx.RLock()
+	defer ar.stopMtx.RUnlock()
+
+	select {
+	case <-ar.stopping:
+		atomic.AddUint64(&ar.dropped, 1)
+		return
+	default:
+	}
+
+	select {
+	case ar.queue <- doc:
+	default:
+		atomic.AddUint64(&ar.dropped, 1)
+	}
+}
+
+func (ar *AsyncReporter) Flush(ctx context.Context) ErrorWithStack {
+	done := make(chan struct{})
+
+	select {
+	case ar.flushes <- reporterFlush{ctx, done}:
+	case <-ar.closed:
+		return nil
+	case <-ctx.Done():
+		return AttachStackToError(ctx.Err(), 0)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return AttachStackToError(ctx.Err(), 0)
+	}
+}
+
+// Close stops the AsyncReporter after sending all queued errors with $ctx.
+// It waits for that like Flush(). Further calls of any method are no-ops,
+// except that Report() counts the errors as dropped.
+func (ar *AsyncReporter) Close(ctx context.Context) (err ErrorWithStack) {
+	ar.close.Do(func() {
+		ar.stopCtx = ctx
+
+		ar.stopMtx.Lock()
+		close(ar.stopping)
+		ar.stopMtx.Unlock()
+
+		select {
+		case <-ar.closed:
+		case <-ctx.Done():
+			err = AttachStackToError(ctx.Err(), 0)
+		}
+	})
+
+	return
+}
+
+// Dropped returns the amount of errors dropped so far due to a full queue, a Sink error or being closed.
+func (ar *AsyncReporter) Dropped() uint64 {
+	return atomic.LoadUint64(&ar.dropped)
+}
+
+// run sends the queued errors in batches until stopped.
+func (ar *AsyncReporter) run() {
+	ticker := time.NewTicker(ar.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]json.RawMessage, 0, ar.opts.BatchSize)
+
+	for {
+		select {
+		case doc := <-ar.queue:
+			if batch = append(batch, doc); len(batch) >= ar.opts.BatchSize {
+				batch = ar.send(context.Background(), batch)
+			}
+		case <-ticker.C:
+			batch = ar.send(context.Background(), batch)
+		case flush := <-ar.flushes:
+			batch = ar.drain(flush.ctx, batch)
+			close(flush.done)
+		case <-ar.stopping:
+			ar.drain(ar.stopCtx, batch)
+			close(ar.closed)
+			return
+		}
+	}
+}
+
+// drain sends $batch and all queued errors and returns $batch emptied.
+func (ar *AsyncReporter) drain(ctx context.Context, batch []json.RawMessage) []json.RawMessage {
+	for {
+		select {
+		case doc := <-ar.queue:
+			if batch = append(batch, doc); len(batch) >= ar.opts.BatchSize {
+				batch = ar.send(ctx, batch)
+			}
+		default:
+			return ar.send(ctx, batch)
+		}
+	}
+}
+
+// send sends $batch if not empty and returns it emptied.
+func (ar *AsyncReporter) send(ctx context.Context, batch []json.RawMessage) []json.RawMessage {
+	if len(batch) > 0 {
+		if err := ar.sink.Send(ctx, batch); err != nil {
+			ar.fail(err, uint64(len(batch)))
+		}
+	}
+
+	return batch[:0]
+}
+
+// fail counts $dropped errors as dropped due to $err.
+func (ar *AsyncReporter) fail(err ErrorWithStack, dropped uint64) {
+	atomic.AddUint64(&ar.dropped, dropped)
+
+	if ar.opts.OnError != nil {
+		ar.opts.OnError(err)
+	}
+}
+
+// WriterSink writes each error as a JSON line to W.
+type WriterSink struct {
+	// W defaults to os.Stderr.
+	W io.Writer
+}
+
+var _ Sink = WriterSink{}
+
+func (ws WriterSink) Send(_ context.Context, batch []json.RawMessage) ErrorWithStack {
+	w := ws.W
+	if w == nil {
+		w = os.Stderr
+	}
+
+	_, err := w.Write(jsonLines(batch))
+	return AttachStackToError(err, 0)
+}
+
+// FileSink appends each error as a JSON line to the file Path. The file is (re-)opened for each batch.
+type FileSink struct {
+	Path string
+}
+
+var _ Sink = FileSink{}
+
+func (fs FileSink) Send(_ context.Context, batch []json.RawMessage) ErrorWithStack {
+	f, err := os.OpenFile(fs.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	if _, err := f.Write(jsonLines(batch)); err != nil {
+		f.Close()
+		return AttachStackToError(err, 0)
+	}
+
+	return AttachStackToError(f.Close(), 0)
+}
+
+// jsonLines joins $batch to JSON lines.
+func jsonLines(batch []json.RawMessage) []byte {
+	buf := &bytes.Buffer{}
+	for _, doc := range batch {
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// HTTPSink POSTs each batch as JSON array to URL.
+type HTTPSink struct {
+	URL string
+	// Header is added to each request, e.g. for authorization.
+	Header http.Header
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+var _ Sink = HTTPSink{}
+
+func (hs HTTPSink) Send(ctx context.Context, batch []json.RawMessage) ErrorWithStack {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hs.URL, bytes.NewReader(body))
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	for k, v := range hs.Header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := hs.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return AttachStackToError(fmt.Errorf("got HTTP status %s", resp.Status), 0)
+	}
+
+	return nil
+}
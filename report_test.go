@@ -0,0 +1,215 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncReporter_WriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ar := NewAsyncReporter(WriterSink{buf}, ReporterOptions{})
+	defer ar.Close(context.Background())
+
+	ar.Report(AttachFieldsToError(io.EOF, 0, Field{"user", "alice"}))
+	ar.Report(io.ErrUnexpectedEOF)
+	ar.Report(nil)
+	ar.Report(errors.New("third party"))
+
+	if err := ar.Flush(context.Background()); err != nil {
+		t.Fatalf("AsyncReporter#Flush(...): got %#v, expected nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("AsyncReporter: wrote %#v, expected 3 JSON lines", buf.String())
+	}
+
+	var docs [3]struct {
+		Error  string            `json:"error"`
+		Stack  []StackFrame      `json:"stack"`
+		Fields map[string]string `json:"fields"`
+	}
+
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &docs[i]); err != nil {
+			t.Errorf("AsyncReporter: wrote bad JSON %#v: %s", line, err.Error())
+		}
+	}
+
+	if docs[0].Error != "EOF" || len(docs[0].Stack) < 1 || docs[0].Fields["user"] != "alice" {
+		t.Errorf("AsyncReporter: wrote %#v, expected the error with stack and fields", lines[0])
+	}
+
+	if docs[1].Error != "unexpected EOF" || docs[1].Stack != nil {
+		t.Errorf("AsyncReporter: wrote %#v, expected just the error", lines[1])
+	}
+
+	if docs[2].Error != "third party" || len(docs[2].Stack) < 1 {
+		t.Errorf("AsyncReporter: wrote %#v, expected the error with its own stack", lines[2])
+	}
+}
+
+func TestAsyncReporter_Dropped(t *testing.T) {
+	sink := &blockingSink{entered: make(chan struct{}, 10), release: make(chan struct{})}
+	ar := NewAsyncReporter(sink, ReporterOptions{BufferSize: 2, BatchSize: 1})
+	defer ar.Close(context.Background())
+
+	ar.Report(io.EOF)
+	<-sink.entered
+
+	for i := 0; i < 4; i++ {
+		ar.Report(io.EOF)
+	}
+
+	if dropped := ar.Dropped(); dropped != 2 {
+		t.Errorf("AsyncReporter#Dropped(): got %d, expected 2", dropped)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := ar.Flush(ctx); err == nil || RootCause(err) != context.DeadlineExceeded {
+		t.Errorf("AsyncReporter#Flush(...): got %#v, expected context.DeadlineExceeded", err)
+	}
+
+	close(sink.release)
+
+	if err := ar.Flush(context.Background()); err != nil {
+		t.Errorf("AsyncReporter#Flush(...): got %#v, expected nil", err)
+	}
+
+	if sent := sink.count(); sent != 3 {
+		t.Errorf("AsyncReporter: sent %d errors, expected 3", sent)
+	}
+}
+
+func TestAsyncReporter_FileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "errors.jsonl")
+	ar := NewAsyncReporter(FileSink{path}, ReporterOptions{})
+
+	for i := 0; i < 2; i++ {
+		ar.Report(io.EOF)
+
+		if err := ar.Flush(context.Background()); err != nil {
+			t.Errorf("AsyncReporter#Flush(...): got %#v, expected nil", err)
+		}
+	}
+
+	ar.Report(io.ErrUnexpectedEOF)
+
+	if err := ar.Close(context.Background()); err != nil {
+		t.Errorf("AsyncReporter#Close(...): got %#v, expected nil", err)
+	}
+
+	if err := ar.Close(context.Background()); err != nil {
+		t.Errorf("AsyncReporter#Close(...): got %#v, expected nil", err)
+	}
+
+	if err := ar.Flush(context.Background()); err != nil {
+		t.Errorf("AsyncReporter#Flush(...): got %#v, expected nil after Close()", err)
+	}
+
+	ar.Report(io.EOF)
+
+	if dropped := ar.Dropped(); dropped != 1 {
+		t.Errorf("AsyncReporter#Dropped(): got %d, expected 1 after Report() after Close()", dropped)
+	}
+
+	expected := "{\"error\":\"EOF\"}\n{\"error\":\"EOF\"}\n{\"error\":\"unexpected EOF\"}\n"
+	if content, _ := ioutil.ReadFile(path); string(content) != expected {
+		t.Errorf("AsyncReporter: wrote %#v, expected %#v", string(content), expected)
+	}
+}
+
+func TestAsyncReporter_HTTPSink(t *testing.T) {
+	var mtx sync.Mutex
+	var bodies []string
+	var auth string
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mtx.Lock()
+		bodies = append(bodies, string(body))
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		mtx.Unlock()
+	}))
+	defer server.Close()
+
+	var sinkErrors []ErrorWithStack
+	ar := NewAsyncReporter(
+		HTTPSink{URL: server.URL, Header: http.Header{"Authorization": {"Bearer x"}}},
+		ReporterOptions{BatchSize: 2, OnError: func(err ErrorWithStack) { sinkErrors = append(sinkErrors, err) }},
+	)
+	defer ar.Close(context.Background())
+
+	for i := 0; i < 3; i++ {
+		ar.Report(io.EOF)
+	}
+
+	ar.Flush(context.Background())
+
+	mtx.Lock()
+	if expected := `[{"error":"EOF"},{"error":"EOF"}]`; len(bodies) != 2 || bodies[0] != expected ||
+		bodies[1] != `[{"error":"EOF"}]` || auth != "Bearer x" {
+		t.Errorf("AsyncReporter: sent %#v with Authorization %#v, expected 2 batches", bodies, auth)
+	}
+
+	status = http.StatusInternalServerError
+	mtx.Unlock()
+
+	ar.Report(io.EOF)
+	ar.Flush(context.Background())
+
+	if len(sinkErrors) != 1 || !strings.Contains(sinkErrors[0].Error(), "500") || ar.Dropped() != 1 {
+		t.Errorf("AsyncReporter: got Sink errors %#v and %d dropped, expected HTTP 500", sinkErrors, ar.Dropped())
+	}
+}
+
+type blockingSink struct {
+	entered chan struct{}
+	release chan struct{}
+	mtx     sync.Mutex
+	sent    int
+}
+
+var _ Sink = (*blockingSink)(nil)
+
+func (bs *blockingSink) Send(_ context.Context, batch []json.RawMessage) ErrorWithStack {
+	bs.entered <- struct{}{}
+	<-bs.release
+
+	bs.mtx.Lock()
+	bs.sent += len(batch)
+	bs.mtx.Unlock()
+
+	return nil
+}
+
+func (bs *blockingSink) count() int {
+	bs.mtx.Lock()
+	defer bs.mtx.Unlock()
+
+	return bs.sent
+}
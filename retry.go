@@ -0,0 +1,228 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy specifies how Retry retries. The zero value retries forever with exponential backoff.
+type RetryPolicy struct {
+	// InitialBackoff is the delay before the second attempt. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff, if positive, caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after each attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to +/- that fraction of it, e.g. 0.1 for +/- 10%.
+	Jitter float64
+	// MaxAttempts, if positive, limits the amount of attempts.
+	MaxAttempts int
+	// MaxElapsed, if positive, prevents attempts which would start later than that after the first one.
+	MaxElapsed time.Duration
+	// Retryable, if not nil, tells whether an error is worth another attempt.
+	Retryable func(err ErrorWithStack) bool
+}
+
+var (
+	// ErrNotRetryable is the RetryError#Reason if RetryPolicy#Retryable returned false.
+	ErrNotRetryable error = plainError("not retryable")
+	// ErrMaxAttempts is the RetryError#Reason if RetryPolicy#MaxAttempts were made.
+	ErrMaxAttempts error = plainError("max attempts reached")
+	// ErrMaxElapsed is the RetryError#Reason if RetryPolicy#MaxElapsed would be exceeded.
+	ErrMaxElapsed error = plainError("max elapsed time reached")
+)
+
+// Retry calls $f until it succeeds or $policy says to give up. It sleeps between attempts as $policy says.
+// Sleeps are interrupted by $ctx cancellation which also makes Retry give up.
+// Once given up, Retry returns a RetryError with all failed attempts.
+func Retry(ctx context.Context, policy RetryPolicy, f func(context.Context) ErrorWithStack) ErrorWithStack {
+	start := time.Now()
+	var attempts MultiError
+
+	for backoff := policy.initialBackoff(); ; backoff = policy.nextBackoff(backoff) {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+
+		attempts = append(attempts, err)
+
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return RetryError{attempts, ErrNotRetryable}
+		}
+
+		if policy.MaxAttempts > 0 && len(attempts) >= policy.MaxAttempts {
+			return RetryError{attempts, ErrMaxAttempts}
+		}
+
+		delay := policy.jitter(backoff)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return RetryError{attempts, ErrMaxElapsed}
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return RetryError{attempts, ctx.Err()}
+		}
+	}
+}
+
+func (rp RetryPolicy) initialBackoff() time.Duration {
+	if rp.InitialBackoff > 0 {
+		return rp.capBackoff(rp.InitialBackoff)
+	}
+
+	return rp.capBackoff(100 * time.Millisecond)
+}
+
+func (rp RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	next := float64(backoff) * multiplier
+	if next >= math.MaxInt64 {
+		return rp.capBackoff(math.MaxInt64)
+	}
+
+	return rp.capBackoff(time.Duration(next))
+}
+
+func (rp RetryPolicy) capBackoff(backoff time.Duration) time.Duration {
+	if rp.MaxBackoff > 0 && backoff > rp.MaxBackoff {
+		return rp.MaxBackoff
+	}
+
+	return backoff
+}
+
+func (rp RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if rp.Jitter <= 0 {
+		return backoff
+	}
+
+	return time.Duration(float64(backoff) * (1 + rp.Jitter*(2*rand.Float64()-1)))
+}
+
+// RetryError is returned by Retry once it gave up.
+type RetryError struct {
+	// Attempts are the errors of all attempts, the last one last.
+	Attempts MultiError
+	// Reason tells why Retry gave up, e.g. ErrMaxAttempts or context.Canceled.
+	Reason error
+}
+
+var _ error = RetryError{}
+
+func (re RetryError) Error() string {
+	if last := re.last(); last != nil {
+		return fmt.Sprintf("%s: %s", re.header(), last.Error())
+	}
+
+	return re.header()
+}
+
+// header describes re without its attempts.
+func (re RetryError) header() string {
+	return fmt.Sprintf("giving up after %d attempt(s) (%s)", len(re.Attempts), re.reason())
+}
+
+func (re RetryError) reason() string {
+	if re.Reason == nil {
+		return ""
+	}
+
+	return re.Reason.Error()
+}
+
+// last returns the error of the last attempt, if any.
+func (re RetryError) last() ErrorWithStack {
+	if len(re.Attempts) < 1 {
+		return nil
+	}
+
+	return re.Attempts[len(re.Attempts)-1]
+}
+
+var _ fmt.Formatter = RetryError{}
+
+// Format formats all attempts on %+v, each one with its stack.
+func (re RetryError) Format(fs fmt.State, verb rune) {
+	if verb != 'v' || !fs.Flag('+') {
+		formatRedacted(fs, verb, re.Error())
+		return
+	}
+
+	formatRedacted(fs, 's', re.header()+":")
+
+	for i, err := range re.Attempts {
+		fmt.Fprintf(fs, "\n\n#%d: ", i+1)
+		formatRedacted(fs, verb, err)
+	}
+}
+
+var _ json.Marshaler = RetryError{}
+
+func (re RetryError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error    string        `json:"error"`
+		Reason   string        `json:"reason"`
+		Attempts []interface{} `json:"attempts"`
+	}{GetRedactor().Redact(re.Error()), re.reason(), re.Attempts.toJSON()})
+}
+
+var _ StackTracer = RetryError{}
+
+// StackTrace returns the stack of the last attempt, if any.
+func (re RetryError) StackTrace() errors.StackTrace {
+	if last := re.last(); last != nil {
+		return last.StackTrace()
+	}
+
+	return nil
+}
+
+var _ fmt.Stringer = RetryError{}
+
+func (re RetryError) String() string {
+	s, _ := re.MarshalText()
+	return string(s)
+}
+
+var _ encoding.TextMarshaler = RetryError{}
+
+func (re RetryError) MarshalText() (text []byte, err error) {
+	buf := &bytes.Buffer{}
+	re.Format(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v')
+
+	return buf.Bytes(), nil
+}
+
+var _ MultiUnwrapper = RetryError{}
+
+// Unwrap returns Reason, if set, followed by the errors of all attempts,
+// so that e.g. errors.Is(err, context.Canceled) works as well as errors.As on any attempt.
+func (re RetryError) Unwrap() []error {
+	errs := make([]error, 0, len(re.Attempts)+1)
+	if re.Reason != nil {
+		errs = append(errs, re.Reason)
+	}
+
+	for _, err := range re.Attempts {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
@@ -0,0 +1,194 @@
+package fuel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 5}
+	calls := 0
+
+	err := Retry(context.Background(), policy, func(context.Context) ErrorWithStack {
+		calls++
+		if calls < 3 {
+			return AttachStackToError(io.EOF, 0)
+		}
+
+		return nil
+	})
+
+	if err != nil || calls != 3 {
+		t.Errorf("Retry(...): got %#v after %d calls, expected nil after 3", err, calls)
+	}
+
+	calls = 0
+	err = Retry(context.Background(), policy, func(context.Context) ErrorWithStack {
+		calls++
+		return AttachStackToError(fmt.Errorf("attempt %d", calls), 0)
+	})
+
+	re, ok := err.(RetryError)
+	if !ok {
+		t.Fatalf("Retry(...): got %#v, expected a RetryError", err)
+	}
+
+	if calls != 5 || len(re.Attempts) != 5 || re.Reason != ErrMaxAttempts {
+		t.Errorf("Retry(...): got %#v after %d calls, expected 5 attempts", err, calls)
+	}
+
+	if expected := "giving up after 5 attempt(s) (max attempts reached): attempt 5"; re.Error() != expected {
+		t.Errorf("Retry(...).Error(): got %#v, expected %#v", re.Error(), expected)
+	}
+
+	if RootCause(re) != ErrMaxAttempts || len(re.StackTrace()) < 1 {
+		t.Errorf("Retry(...): got %#v and %#v, expected the reason and the last attempt", RootCause(re), re.StackTrace())
+	}
+
+	var unwrapped []string
+	WalkError(re, func(err error, depth int) bool {
+		if depth == 1 {
+			unwrapped = append(unwrapped, err.Error())
+		}
+
+		return true
+	})
+
+	if expected := "max attempts reached,attempt 1,attempt 2,attempt 3,attempt 4,attempt 5"; strings.Join(
+		unwrapped, ",",
+	) != expected {
+		t.Errorf("WalkError(%#v, ...): got %#v, expected %#v", re, unwrapped, expected)
+	}
+
+	text := fmt.Sprintf("%+v", re)
+	if !strings.HasPrefix(text, "giving up after 5 attempt(s) (max attempts reached):\n\n#1: attempt 1\n") ||
+		!strings.Contains(text, "\n\n#5: attempt 5\n") || strings.Count(text, "TestRetry.func2") != 5 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected all attempts with their stacks", re, text)
+	}
+
+	var jsn struct {
+		Error    string `json:"error"`
+		Reason   string `json:"reason"`
+		Attempts []struct {
+			Error string       `json:"error"`
+			Stack []StackFrame `json:"stack"`
+		} `json:"attempts"`
+	}
+
+	if raw, errJS := json.Marshal(re); errJS != nil {
+		t.Errorf("json.Marshal(%#v): got error %#v", re, errJS)
+	} else if errJS := json.Unmarshal(raw, &jsn); errJS != nil || jsn.Reason != "max attempts reached" ||
+		len(jsn.Attempts) != 5 || jsn.Attempts[4].Error != "attempt 5" || len(jsn.Attempts[4].Stack) < 1 {
+		t.Errorf("json.Marshal(%#v): got %#v, expected all attempts with their stacks", re, string(raw))
+	}
+}
+
+func TestRetry_Retryable(t *testing.T) {
+	calls := 0
+	policy := RetryPolicy{
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(err ErrorWithStack) bool { return RootCause(err) != io.ErrUnexpectedEOF },
+	}
+
+	err := Retry(context.Background(), policy, func(context.Context) ErrorWithStack {
+		calls++
+		if calls < 2 {
+			return AttachStackToError(io.EOF, 0)
+		}
+
+		return AttachStackToError(io.ErrUnexpectedEOF, 0)
+	})
+
+	if re, ok := err.(RetryError); !ok || re.Reason != ErrNotRetryable || len(re.Attempts) != 2 {
+		t.Errorf("Retry(...): got %#v, expected a non-retryable RetryError after 2 attempts", err)
+	}
+}
+
+func TestRetry_MaxElapsed(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 20 * time.Millisecond, Multiplier: 1, MaxElapsed: 50 * time.Millisecond}
+	var err ErrorWithStack
+
+	assertTakesTime(t, 40*time.Millisecond, 30*time.Millisecond, func() {
+		err = Retry(context.Background(), policy, func(context.Context) ErrorWithStack {
+			return AttachStackToError(io.EOF, 0)
+		})
+	})
+
+	if re, ok := err.(RetryError); !ok || re.Reason != ErrMaxElapsed || len(re.Attempts) != 3 {
+		t.Errorf("Retry(...): got %#v, expected a RetryError due to elapsed time after 3 attempts", err)
+	}
+}
+
+func TestRetry_Context(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var err ErrorWithStack
+
+	assertTakesTime(t, 20*time.Millisecond, 30*time.Millisecond, func() {
+		err = Retry(ctx, RetryPolicy{InitialBackoff: time.Hour}, func(context.Context) ErrorWithStack {
+			return AttachStackToError(io.EOF, 0)
+		})
+	})
+
+	if re, ok := err.(RetryError); !ok || re.Reason != context.DeadlineExceeded || len(re.Attempts) != 1 {
+		t.Errorf("Retry(...): got %#v, expected a RetryError due to the context after 1 attempt", err)
+	}
+
+	if RootCause(err) != context.DeadlineExceeded {
+		t.Errorf("RootCause(%#v): got %#v, expected context.DeadlineExceeded", err, RootCause(err))
+	}
+}
+
+func TestRetryError_empty(t *testing.T) {
+	re := RetryError{}
+
+	if expected := "giving up after 0 attempt(s) ()"; re.Error() != expected {
+		t.Errorf("RetryError{}.Error(): got %#v, expected %#v", re.Error(), expected)
+	}
+
+	if actual := fmt.Sprintf("%+v", re); actual != "giving up after 0 attempt(s) ():" {
+		t.Errorf("fmt.Sprintf(\"%%+v\", RetryError{}): got %#v", actual)
+	}
+
+	if len(re.StackTrace()) != 0 || len(re.Unwrap()) != 0 {
+		t.Errorf("RetryError{}: got stack %#v and %#v unwrapped, expected none", re.StackTrace(), re.Unwrap())
+	}
+
+	if actual, err := json.Marshal(re); err != nil || string(actual) !=
+		`{"error":"giving up after 0 attempt(s) ()","reason":"","attempts":[]}` {
+		t.Errorf("json.Marshal(RetryError{}): got %s and %#v", actual, err)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	backoff := policy.initialBackoff()
+
+	for _, expected := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if backoff = policy.nextBackoff(backoff); backoff != expected {
+			t.Errorf("%#v.nextBackoff(...): got %s, expected %s", policy, backoff, expected)
+		}
+	}
+
+	if actual := (RetryPolicy{}).initialBackoff(); actual != 100*time.Millisecond {
+		t.Errorf("RetryPolicy{}.initialBackoff(): got %s, expected 100ms", actual)
+	}
+
+	if actual := (RetryPolicy{}).nextBackoff(time.Duration(math.MaxInt64 / 3 * 2)); actual < 0 {
+		t.Errorf("RetryPolicy{}.nextBackoff(...): got %s, expected no overflow", actual)
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if actual := policy.jitter(time.Second); actual < time.Second/2 || actual > time.Second*3/2 {
+			t.Errorf("%#v.jitter(1s): got %s, expected 0.5s - 1.5s", policy, actual)
+		}
+	}
+}
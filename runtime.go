@@ -0,0 +1,681 @@
+package fuel
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Framer is implemented by errors which provide their stack as symbolized frames,
+// e.g. because they don't have one of the current process.
+type Framer interface {
+	StackFrames() []StackFrame
+}
+
+// StackFrame is a symbolized errors.Frame.
+type StackFrame struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+	// Repeated is the amount of directly following frames of the same function collapsed into this one.
+	Repeated int `json:"repeated,omitempty"`
+	// SourceContext, if not nil, holds the source code around Line, see SetSourceContext.
+	*SourceContext
+}
+
+var _ fmt.Formatter = StackFrame{}
+
+// Format formats $sf exactly like an errors.Frame.
+func (sf StackFrame) Format(fs fmt.State, verb rune) {
+	switch verb {
+	case 's':
+		if fs.Flag('+') {
+			io.WriteString(fs, sf.function())
+			io.WriteString(fs, "\n\t")
+			io.WriteString(fs, sf.file())
+		} else {
+			io.WriteString(fs, path.Base(sf.file()))
+		}
+	case 'd':
+		io.WriteString(fs, strconv.FormatInt(int64(sf.Line), 10))
+	case 'n':
+		name := sf.function()
+		name = name[strings.LastIndex(name, "/")+1:]
+		io.WriteString(fs, name[strings.Index(name, ".")+1:])
+	case 'v':
+		sf.Format(fs, 's')
+		io.WriteString(fs, ":")
+		sf.Format(fs, 'd')
+	}
+}
+
+func (sf StackFrame) function() string {
+	if sf.Function == "" {
+		return "unknown"
+	}
+
+	return sf.Function
+}
+
+func (sf StackFrame) file() string {
+	if sf.File == "" {
+		return "unknown"
+	}
+
+	return sf.File
+}
+
+// FramesOf returns the stack of $st as symbolized frames.
+func FramesOf(st StackTracer) []StackFrame {
+	if f, ok := st.(Framer); ok {
+		return f.StackFrames()
+	}
+
+	return symbolizeStack(st.StackTrace())
+}
+
+// StackSegment marks where the part of a stack belonging to a spawning goroutine begins.
+type StackSegment struct {
+	// Start is the index of the segment's first frame, e.g. in RemoteError#Frames.
+	Start int `json:"start"`
+	// CreatedBy names what spawned the goroutine of the previous segment, e.g. "ErrorGroup.Go".
+	CreatedBy string `json:"created_by"`
+}
+
+// frameSegment is a symbolized part of a stack belonging to one goroutine.
+type frameSegment struct {
+	createdBy string
+	frames    []StackFrame
+}
+
+// segmentBounds splits $n items into ranges by $segments, ignoring invalid ones.
+func segmentBounds(n int, segments []StackSegment) (bounds [][2]int, createdBy []string) {
+	start := 0
+	createdBy = append(createdBy, "")
+
+	for _, segment := range segments {
+		if segment.Start >= start && segment.Start <= n {
+			bounds = append(bounds, [2]int{start, segment.Start})
+			createdBy = append(createdBy, segment.CreatedBy)
+			start = segment.Start
+		}
+	}
+
+	return append(bounds, [2]int{start, n}), createdBy
+}
+
+// stackSegments tells which parts of $frames belong to which goroutines. Stacks assembled across goroutines,
+// e.g. by ErrorGroup, consist of stacks each ending with runtime.goexit (see appendSpawnStack)
+// and beginning with the spawner.
+func stackSegments(frames []StackFrame) []StackSegment {
+	var segments []StackSegment
+
+	for i := 0; i+1 < len(frames); i++ {
+		if frames[i].Function == "runtime.goexit" {
+			segments = append(segments, StackSegment{i + 1, spawnerName(frames[i+1].Function)})
+		}
+	}
+
+	return segments
+}
+
+// spawnerName shortens the fully qualified function $name to e.g. "ErrorGroup.Go".
+func spawnerName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	return strings.NewReplacer("(*", "", ")", "").Replace(name[strings.Index(name, ".")+1:])
+}
+
+// splitSegments filters $frames goroutine by goroutine.
+func splitSegments(frames []StackFrame, segments []StackSegment, filter *StackFilter) []frameSegment {
+	bounds, createdBy := segmentBounds(len(frames), segments)
+	segs := make([]frameSegment, 0, len(bounds))
+
+	for i, bound := range bounds {
+		segs = append(segs, frameSegment{createdBy[i], filter.Apply(frames[bound[0]:bound[1]])})
+	}
+
+	return segs
+}
+
+// joinSegments is the reverse of splitSegments.
+func joinSegments(segs []frameSegment) (frames []StackFrame, segments []StackSegment) {
+	for i, seg := range segs {
+		if i > 0 {
+			segments = append(segments, StackSegment{len(frames), seg.createdBy})
+		}
+
+		frames = append(frames, seg.frames...)
+	}
+
+	return
+}
+
+// formatSegments formats $segs like formatFrames, but separates the goroutines on %+v.
+func formatSegments(fs fmt.State, verb rune, segs []frameSegment) {
+	if verb != 'v' || !fs.Flag('+') {
+		frames, _ := joinSegments(segs)
+		formatFrames(fs, verb, frames)
+		return
+	}
+
+	for i, seg := range segs {
+		if i > 0 {
+			fmt.Fprintf(fs, "\ncreated by %s", seg.createdBy)
+
+			if len(seg.frames) > 0 {
+				fmt.Fprintf(fs, " at %v", seg.frames[0])
+			}
+		}
+
+		formatFrames(fs, verb, seg.frames)
+	}
+}
+
+// renderedSegmentsOf returns the stack of $st as rendered by e.g. AdvancedError#Format().
+func renderedSegmentsOf(st StackTracer) []frameSegment {
+	return renderedSegmentsVia(st, GetStackFilter())
+}
+
+// renderedSegmentsVia is like renderedSegmentsOf, but renders via $filter unless $st has an own one.
+func renderedSegmentsVia(st StackTracer, filter *StackFilter) []frameSegment {
+	switch e := st.(type) {
+	case AdvancedError:
+		return e.renderedSegments(filter)
+	case RemoteError:
+		return e.renderedSegments(filter)
+	case FilteredError:
+		return e.renderedSegments()
+	case Framer:
+		return []frameSegment{{"", filter.Apply(e.StackFrames())}}
+	default:
+		return withSourceContext([]frameSegment{{"", filter.Apply(symbolizeStack(st.StackTrace()))}})
+	}
+}
+
+// outermostStackTracer returns the outermost error wrapped by $err which has a non-empty stack, if any.
+// Unlike DeepestStackTracer it prefers e.g. the stack ErrorGroup assembled across goroutines.
+func outermostStackTracer(err error) StackTracer {
+	var outermost StackTracer
+
+	WalkError(err, func(err error, _ int) bool {
+		if st, ok := err.(StackTracer); ok && hasStack(st) {
+			outermost = st
+			return false
+		}
+
+		return true
+	})
+
+	return outermost
+}
+
+// StackFilter specifies which frames of a stack to render.
+type StackFilter struct {
+	// DropRuntime drops frames of the package runtime, e.g. runtime.goexit.
+	DropRuntime bool
+	// DropStdlib drops frames of the standard library, including the runtime.
+	// These are recognized by their files in GOROOT/src as compiled into this binary,
+	// so only the runtime ones are recognized if the binary was built with -trimpath.
+	DropStdlib bool
+	// Prefixes, if not empty, keeps only frames of functions starting with one of them, e.g. a module path.
+	Prefixes []string
+	// MaxDepth, if positive, keeps only as many (remaining) frames at the top.
+	MaxDepth int
+	// CollapseRecursion collapses directly repeated frames of the same function into one.
+	// This happens before dropping any frames, so it doesn't collapse e.g. callbacks passed through the runtime.
+	CollapseRecursion bool
+	// TrimPaths makes file paths relative to GOROOT/src, GOPATH/pkg/mod, GOPATH/src or the module root (see go.mod).
+	TrimPaths bool
+	// MaskLines replaces all line numbers with 0.
+	MaskLines bool
+}
+
+// Apply returns the frames of $frames passing $sf. A nil $sf passes all frames.
+func (sf *StackFilter) Apply(frames []StackFrame) []StackFrame {
+	if sf == nil || len(frames) < 1 {
+		return frames
+	}
+
+	if sf.CollapseRecursion {
+		frames = collapseRecursion(frames)
+	}
+
+	filtered := make([]StackFrame, 0, len(frames))
+
+	for _, frame := range frames {
+		if sf.keep(frame) {
+			filtered = append(filtered, frame)
+		}
+	}
+
+	if sf.MaxDepth > 0 && len(filtered) > sf.MaxDepth {
+		filtered = filtered[:sf.MaxDepth]
+	}
+
+	for i := range filtered {
+		if sf.TrimPaths {
+			filtered[i].File = trimPath(filtered[i].File)
+		}
+
+		if sf.MaskLines {
+			filtered[i].Line = 0
+		}
+	}
+
+	return filtered
+}
+
+// collapseRecursion returns a copy of $frames with directly repeated frames of the same function collapsed.
+func collapseRecursion(frames []StackFrame) []StackFrame {
+	collapsed := make([]StackFrame, 0, len(frames))
+
+	for _, frame := range frames {
+		if len(collapsed) > 0 {
+			if last := &collapsed[len(collapsed)-1]; last.Function == frame.Function {
+				last.Repeated += 1 + frame.Repeated
+				continue
+			}
+		}
+
+		collapsed = append(collapsed, frame)
+	}
+
+	return collapsed
+}
+
+func (sf *StackFilter) keep(frame StackFrame) bool {
+	if sf.DropRuntime || sf.DropStdlib {
+		if funcPackage(frame.Function) == "runtime" {
+			return false
+		}
+
+		if goRoot := goRootSrc(); sf.DropStdlib && goRoot != "" && strings.HasPrefix(frame.File, goRoot) {
+			return false
+		}
+	}
+
+	if len(sf.Prefixes) < 1 {
+		return true
+	}
+
+	for _, prefix := range sf.Prefixes {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// funcPackage returns the package path of the fully qualified function $name.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/") + 1
+	if dot := strings.Index(name[slash:], "."); dot >= 0 {
+		return name[:slash+dot]
+	}
+
+	return name
+}
+
+var stackFilter atomic.Value
+
+func init() {
+	stackFilter.Store((*StackFilter)(nil))
+}
+
+// SetStackFilter sets the StackFilter used for rendering stacks unless overridden per error.
+// $filter must not be modified afterwards. nil disables filtering.
+func SetStackFilter(filter *StackFilter) {
+	stackFilter.Store(filter)
+}
+
+// GetStackFilter returns the StackFilter set by SetStackFilter.
+func GetStackFilter() *StackFilter {
+	return stackFilter.Load().(*StackFilter)
+}
+
+// FilteredError renders the stack of Err via Filter instead of the StackFilter set by SetStackFilter.
+// Filter must not be modified afterwards. nil disables filtering.
+type FilteredError struct {
+	Err    ErrorWithStack
+	Filter *StackFilter
+}
+
+var _ Causer = FilteredError{}
+
+func (fe FilteredError) Cause() error {
+	return fe.Err
+}
+
+var _ error = FilteredError{}
+
+func (fe FilteredError) Error() string {
+	return fe.Err.Error()
+}
+
+var _ fmt.Formatter = FilteredError{}
+
+// Format formats fe.Err like it would format itself, just with fe.Filter.
+func (fe FilteredError) Format(fs fmt.State, verb rune) {
+	switch e := fe.Err.(type) {
+	case AdvancedError:
+		e.format(fs, verb, fe.Filter)
+	case RemoteError:
+		e.format(fs, verb, fe.Filter)
+	default:
+		FormatNonFormatter(fs, verb, fe.Err)
+	}
+}
+
+var _ json.Marshaler = FilteredError{}
+
+func (fe FilteredError) MarshalJSON() ([]byte, error) {
+	switch e := fe.Err.(type) {
+	case AdvancedError:
+		return e.marshalJSON(fe.Filter)
+	case RemoteError:
+		return e.marshalJSON(fe.Filter)
+	default:
+		return json.Marshal(errorToJSONDocument(fe.Err))
+	}
+}
+
+// renderedSegments returns the stack of fe.Err as rendered by fe.Format().
+func (fe FilteredError) renderedSegments() []frameSegment {
+	return renderedSegmentsVia(fe.Err, fe.Filter)
+}
+
+var _ Framer = FilteredError{}
+
+func (fe FilteredError) StackFrames() []StackFrame {
+	return FramesOf(fe.Err)
+}
+
+var _ StackTracer = FilteredError{}
+
+func (fe FilteredError) StackTrace() errors.StackTrace {
+	return fe.Err.StackTrace()
+}
+
+var _ fmt.Stringer = FilteredError{}
+
+func (fe FilteredError) String() string {
+	s, _ := fe.MarshalText()
+	return string(s)
+}
+
+var _ encoding.TextMarshaler = FilteredError{}
+
+func (fe FilteredError) MarshalText() (text []byte, err error) {
+	buf := &bytes.Buffer{}
+	fe.Format(&Formatable{Output: buf, Flags: map[int]struct{}{'+': {}}}, 'v')
+
+	return buf.Bytes(), nil
+}
+
+var _ Unwrapper = FilteredError{}
+
+func (fe FilteredError) Unwrap() error {
+	return fe.Err
+}
+
+// CaptureMode tells whether and how to capture stacks, see StackCapture.
+type CaptureMode uint8
+
+const (
+	// CaptureFull captures complete stacks.
+	CaptureFull CaptureMode = iota
+	// CaptureOff captures no stacks at all.
+	CaptureOff
+	// CaptureShallow captures only the top StackCapture#Depth frames.
+	CaptureShallow
+	// CaptureSampled captures only every StackCapture#Rate-th stack, but completely.
+	CaptureSampled
+)
+
+// StackCapture is a policy on capturing stacks by AttachStackToError(), ErrorGroup and RunQueue.
+// The zero value captures complete stacks.
+type StackCapture struct {
+	Mode  CaptureMode
+	Depth int
+	Rate  uint64
+}
+
+var (
+	stackCapture  atomic.Value
+	stackCaptures uint64
+	spawnCaptures uint64
+)
+
+func init() {
+	stackCapture.Store(StackCapture{})
+}
+
+// SetStackCapture sets the process-wide StackCapture policy. It may be changed at any time.
+func SetStackCapture(policy StackCapture) {
+	stackCapture.Store(policy)
+}
+
+// GetStackCapture returns the StackCapture policy set by SetStackCapture.
+func GetStackCapture() StackCapture {
+	return stackCapture.Load().(StackCapture)
+}
+
+// captureStack returns an errors.StackTrace of the calling goroutine as specified by GetStackCapture()
+// without captureStack itself and $skip additional frames at the top.
+func captureStack(skip int) errors.StackTrace {
+	policy := GetStackCapture()
+	if !policy.sample() {
+		return nil
+	}
+
+	if policy.Mode == CaptureShallow {
+		stack := make(errors.StackTrace, policy.Depth)
+
+		return stack[:runtime.Callers(
+			1+ // runtime.Callers
+				1+ // captureStack
+				skip,
+			stackAsRaw(stack),
+		)]
+	}
+
+	return GetStack(
+		1 + // captureStack
+			skip,
+	)
+}
+
+// captureSpawnStack is like captureStack, but for the stack of a goroutine spawning another one, e.g. by
+// ErrorGroup#Go(). In CaptureSampled mode spawns are sampled on their own, i.e. every StackCapture#Rate-th spawn
+// stack is captured without counting as a sample of errors. So the sampled errors of the spawned goroutines
+// (see appendSpawnStack) get the stack of their spawner only if that one has been sampled, too.
+func captureSpawnStack(skip int) errors.StackTrace {
+	if policy := GetStackCapture(); policy.Mode == CaptureSampled {
+		if !policy.sampleVia(&spawnCaptures) {
+			return nil
+		}
+
+		return GetStack(
+			1 + // captureSpawnStack
+				skip,
+		)
+	}
+
+	return captureStack(
+		1 + // captureSpawnStack
+			skip,
+	)
+}
+
+// appendSpawnStack returns $stack followed by $spawnStack (see captureSpawnStack)
+// unless $stack hasn't been sampled (see CaptureSampled).
+func appendSpawnStack(stack, spawnStack errors.StackTrace) errors.StackTrace {
+	if len(spawnStack) < 1 || len(stack) < 1 && GetStackCapture().Mode == CaptureSampled {
+		return stack
+	}
+
+	joined := make(errors.StackTrace, 0, len(stack)+1+len(spawnStack))
+	joined = append(joined, stack...)
+
+	// A stack cut by CaptureShallow or errors.WithStack() lacks the runtime.goexit which tells stackSegments
+	// where the goroutine ends. Mark that boundary here as the spawned goroutine is known to end at this point.
+	if boundary := goexitPC(); len(stack) > 0 && boundary != 0 && stackAsRaw(stack)[len(stack)-1] != boundary {
+		joined = append(joined, errors.Frame(boundary))
+	}
+
+	return append(joined, spawnStack...)
+}
+
+var (
+	goexitOnce sync.Once
+	goexit     uintptr
+)
+
+// goexitPC returns the PC of runtime.goexit at the bottom of every goroutine's stack, 0 if not found.
+func goexitPC() uintptr {
+	goexitOnce.Do(func() {
+		found := make(chan uintptr, 1)
+
+		go func() {
+			var pc uintptr
+			if raw := stackAsRaw(GetStack(0)); len(raw) > 0 {
+				if fn := runtime.FuncForPC(raw[len(raw)-1] - 1); fn != nil && fn.Name() == "runtime.goexit" {
+					pc = raw[len(raw)-1]
+				}
+			}
+
+			found <- pc
+		}()
+
+		goexit = <-found
+	})
+
+	return goexit
+}
+
+// sample tells whether to capture a stack at all as specified by $sc.
+// In CaptureSampled mode that counts as a sample, so call it once per error.
+func (sc StackCapture) sample() bool {
+	return sc.sampleVia(&stackCaptures)
+}
+
+// sampleVia is like sample, but counts samples in $captures.
+func (sc StackCapture) sampleVia(captures *uint64) bool {
+	switch sc.Mode {
+	case CaptureOff:
+		return false
+	case CaptureShallow:
+		return sc.Depth > 0
+	case CaptureSampled:
+		return sc.Rate < 2 || atomic.AddUint64(captures, 1)%sc.Rate == 0
+	default:
+		return true
+	}
+}
+
+// frameCache maps PCs to their []StackFrame. It's process-wide and bounded by the amount of code.
+var frameCache sync.Map
+
+// symbolizeStack resolves $stack to symbolized frames, including inlined ones.
+// PCs resolved once are cached for all further calls.
+func symbolizeStack(stack errors.StackTrace) []StackFrame {
+	if len(stack) < 1 {
+		return nil
+	}
+
+	frames := make([]StackFrame, 0, len(stack))
+	for _, pc := range stackAsRaw(stack) {
+		frames = append(frames, symbolizePC(pc)...)
+	}
+
+	return frames
+}
+
+// symbolizeStackFlat is like symbolizeStack, but resolves each PC only to its innermost frame like errors.Frame.
+// So formatFrames renders the result exactly like errors.StackTrace#Format(), but from the cache.
+func symbolizeStackFlat(stack errors.StackTrace) []StackFrame {
+	if len(stack) < 1 {
+		return nil
+	}
+
+	frames := make([]StackFrame, 0, len(stack))
+	for _, pc := range stackAsRaw(stack) {
+		frames = append(frames, symbolizePC(pc)[0])
+	}
+
+	return frames
+}
+
+// symbolizePC resolves $pc to its frame and the ones inlined into it, innermost first, via frameCache.
+func symbolizePC(pc uintptr) []StackFrame {
+	if cached, ok := frameCache.Load(pc); ok {
+		return cached.([]StackFrame)
+	}
+
+	var resolved []StackFrame
+	cf := runtime.CallersFrames([]uintptr{pc})
+
+	for {
+		fr, more := cf.Next()
+		resolved = append(resolved, StackFrame{File: fr.File, Line: fr.Line, Function: fr.Function})
+
+		if !more {
+			break
+		}
+	}
+
+	frameCache.Store(pc, resolved)
+	return resolved
+}
+
+// formatFrames formats $frames exactly like an errors.StackTrace.
+func formatFrames(fs fmt.State, verb rune, frames []StackFrame) {
+	switch verb {
+	case 'v':
+		switch {
+		case fs.Flag('+'):
+			for _, frame := range frames {
+				io.WriteString(fs, "\n")
+				frame.Format(fs, verb)
+
+				if frame.SourceContext != nil {
+					formatSourceContext(fs, frame.SourceContext, frame.Line)
+				}
+
+				if frame.Repeated > 0 {
+					fmt.Fprintf(fs, "\n... %d more frames of the same function", frame.Repeated)
+				}
+			}
+		case fs.Flag('#'):
+			fmt.Fprintf(fs, "%#v", frames)
+		default:
+			formatFrameSlice(fs, verb, frames)
+		}
+	case 's':
+		formatFrameSlice(fs, verb, frames)
+	}
+}
+
+func formatFrameSlice(fs fmt.State, verb rune, frames []StackFrame) {
+	io.WriteString(fs, "[")
+
+	for i, frame := range frames {
+		if i > 0 {
+			io.WriteString(fs, " ")
+		}
+
+		frame.Format(fs, verb)
+	}
+
+	io.WriteString(fs, "]")
+}
@@ -0,0 +1,362 @@
+package fuel
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStackFrame_Format(t *testing.T) {
+	stack := GetStack(0)[:1]
+	frame := symbolizeStack(stack)[0]
+
+	for _, format := range []string{"%s", "%+s", "%d", "%n", "%v", "%+v"} {
+		if actual, expected := fmt.Sprintf(format, frame), fmt.Sprintf(format, stack[0]); actual != expected {
+			t.Errorf("fmt.Sprintf(%#v, %#v): got %#v, expected %#v", format, frame, actual, expected)
+		}
+	}
+
+	if actual := fmt.Sprintf("%+v", StackFrame{}); actual != "unknown\n\tunknown:0" {
+		t.Errorf("fmt.Sprintf(\"%%+v\", StackFrame{}): got %#v, expected \"unknown\\n\\tunknown:0\"", actual)
+	}
+}
+
+func TestFramesOf(t *testing.T) {
+	err := errors.New("")
+	if actual := FramesOf(err.(StackTracer)); len(actual) != len(err.(StackTracer).StackTrace()) {
+		t.Errorf("FramesOf(%#v): got %d frames, expected %d", err, len(actual), len(err.(StackTracer).StackTrace()))
+	}
+
+	frames := []StackFrame{{File: "a.go", Line: 1, Function: "a"}}
+	if actual := FramesOf(RemoteError{Frames: frames}); len(actual) != 1 || actual[0] != frames[0] {
+		t.Errorf("FramesOf(RemoteError{...}): got %#v, expected %#v", actual, frames)
+	}
+}
+
+func TestFormatFrames(t *testing.T) {
+	stack := GetStack(0)[:2]
+	frames := symbolizeStack(stack)
+
+	for _, format := range []string{"%s", "%v", "%+v"} {
+		actual := fmt.Sprintf(format, formatterFunc(func(fs fmt.State, verb rune) { formatFrames(fs, verb, frames) }))
+		if expected := fmt.Sprintf(format, stack); actual != expected {
+			t.Errorf("formatFrames(%#v, %#v): got %#v, expected %#v", format, frames, actual, expected)
+		}
+	}
+}
+
+func TestStackFilter_Apply(t *testing.T) {
+	frames := []StackFrame{
+		{Function: "github.com/Al2Klimov/FUeL.go.recurse"},
+		{Function: "github.com/Al2Klimov/FUeL.go.recurse"},
+		{Function: "github.com/Al2Klimov/FUeL.go.recurse", Repeated: 2},
+		{Function: "net/http.(*conn).serve", File: goRootSrc() + "net/http/server.go"},
+		{Function: "github.com/Al2Klimov/FUeL.go.TestStackFilter_Apply"},
+		{Function: "main.main"},
+		{Function: "testing.tRunner", File: goRootSrc() + "testing/testing.go"},
+		{Function: "runtime.goexit"},
+	}
+
+	assertStackFilter_Apply(t, nil, frames, frames)
+	assertStackFilter_Apply(t, &StackFilter{}, frames, frames)
+	assertStackFilter_Apply(t, &StackFilter{DropRuntime: true}, frames, frames[:7])
+	assertStackFilter_Apply(t, &StackFilter{MaxDepth: 2}, frames, frames[:2])
+
+	assertStackFilter_Apply(
+		t, &StackFilter{DropStdlib: true}, frames,
+		[]StackFrame{frames[0], frames[1], frames[2], frames[4], frames[5]},
+	)
+
+	assertStackFilter_Apply(
+		t, &StackFilter{Prefixes: []string{"main.", "net/"}}, frames, []StackFrame{frames[3], frames[5]},
+	)
+
+	assertStackFilter_Apply(
+		t, &StackFilter{CollapseRecursion: true, DropRuntime: true, MaxDepth: 3}, frames,
+		[]StackFrame{{Function: frames[0].Function, Repeated: 4}, frames[3], frames[4]},
+	)
+
+	dotless := []StackFrame{
+		{Function: "myapp/handler.serve", File: "/home/alice/myapp/handler/serve.go"},
+		{Function: "myapp/handler.serve", File: "/home/alice/myapp/handler/serve.go"},
+		{Function: "sort.Slice", File: goRootSrc() + "sort/slice.go"},
+		{Function: "myapp/handler.serve", File: "/home/alice/myapp/handler/serve.go"},
+	}
+
+	assertStackFilter_Apply(
+		t, &StackFilter{DropStdlib: true, CollapseRecursion: true}, dotless,
+		[]StackFrame{{Function: dotless[0].Function, File: dotless[0].File, Repeated: 1}, dotless[3]},
+	)
+}
+
+func assertStackFilter_Apply(t *testing.T, sf *StackFilter, in, out []StackFrame) {
+	t.Helper()
+
+	if actual := sf.Apply(in); fmt.Sprint(actual) != fmt.Sprint(out) {
+		t.Errorf("(%#v).Apply(%#v): got %#v, expected %#v", sf, in, actual, out)
+	}
+}
+
+func TestSetStackFilter(t *testing.T) {
+	var stack errors.StackTrace
+	recurse(8, func() { stack = GetStack(0) })
+
+	ae := AdvancedError{Err: io.EOF, Stack: stack}
+	full := fmt.Sprintf("%+v", ae)
+
+	SetStackFilter(&StackFilter{MaxDepth: 2})
+	defer SetStackFilter(nil)
+
+	if actual := strings.Count(fmt.Sprintf("%+v", ae), "\n"); actual != 4 {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %d lines, expected 4", ae, actual+1)
+	}
+
+	if jsn, _ := ae.MarshalJSON(); strings.Count(string(jsn), `"function"`) != 2 {
+		t.Errorf("AdvancedError#MarshalJSON(): got %#v, expected 2 frames", string(jsn))
+	}
+
+	if text, _ := ae.MarshalText(); strings.Count(string(text), "\n") != 4 {
+		t.Errorf("AdvancedError#MarshalText(): got %#v, expected 2 frames", string(text))
+	}
+
+	fe := FilteredError{ae, &StackFilter{CollapseRecursion: true}}
+	if actual := fmt.Sprintf("%+v", fe); len(actual) >= len(full) ||
+		!strings.Contains(actual, "\n... 8 more frames of the same function\n") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected collapsed recursion", fe, actual)
+	}
+
+	if jsn, _ := fe.MarshalJSON(); !strings.Contains(string(jsn), `"repeated":8`) {
+		t.Errorf("FilteredError#MarshalJSON(): got %#v, expected collapsed recursion", string(jsn))
+	}
+
+	if actual := fmt.Sprintf("%+v", Wrap(fe, "x")); !strings.HasPrefix(actual, "x\nEOF\n") ||
+		!strings.Contains(actual, "\n... 8 more frames of the same function\n") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", Wrap(%#v, \"x\")): got %#v, expected collapsed recursion", fe, actual)
+	}
+}
+
+func TestSetStackCapture(t *testing.T) {
+	defer SetStackCapture(StackCapture{})
+
+	SetStackCapture(StackCapture{Mode: CaptureOff})
+
+	if ae, ok := AttachStackToError(io.EOF, 0).(AdvancedError); !ok || ae.Stack != nil {
+		t.Errorf("AttachStackToError(io.EOF, 0): got %#v, expected AdvancedError without stack", ae)
+	}
+
+	f := Formatable{Output: failWrite{io.EOF}}
+	if f.Write(nil); f.Error == nil || f.Error.StackTrace() != nil {
+		t.Errorf("Formatable#Write(nil): Formatable#Error %#v, expected ErrorWithStack without stack", f.Error)
+	}
+
+	eg := NewErrorGroup(context.Background(), 0)
+	eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+
+	if ae, ok := eg.Wait().(AdvancedError); !ok || ae.Stack != nil {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected AdvancedError without stack", ae)
+	}
+
+	eg = NewErrorGroup(context.Background(), 0)
+	eg.Go(1, func(context.Context) ErrorWithStack { panic(42) })
+
+	if ae, ok := eg.Wait().(AdvancedError); !ok || ae.Stack != nil {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected AdvancedError without stack", ae)
+	}
+
+	SetStackCapture(StackCapture{Mode: CaptureShallow, Depth: 3})
+
+	if ae, ok := AttachStackToError(io.EOF, 0).(AdvancedError); !ok || len(ae.Stack) != 3 {
+		t.Errorf("AttachStackToError(io.EOF, 0): got %#v, expected AdvancedError with 3 frames", ae)
+	} else if actual := fmt.Sprintf("%n", ae.Stack[0]); actual != "TestSetStackCapture" {
+		t.Errorf("AttachStackToError(io.EOF, 0): got %s on top of the stack, expected TestSetStackCapture", actual)
+	}
+
+	SetStackCapture(StackCapture{Mode: CaptureSampled, Rate: 4})
+
+	var captured int
+	for i := 0; i < 8; i++ {
+		if len(AttachStackToError(io.EOF, 0).StackTrace()) > 0 {
+			captured++
+		}
+	}
+
+	if captured != 2 {
+		t.Errorf("AttachStackToError(io.EOF, 0): captured %d of 8 stacks, expected 2", captured)
+	}
+
+	captured = 0
+	for i := 0; i < 8; i++ {
+		if len(captureSpawnStack(0)) > 0 {
+			captured++
+		}
+	}
+
+	if captured != 2 {
+		t.Errorf("captureSpawnStack(0): captured %d of 8 stacks, expected 2", captured)
+	}
+
+	SetStackCapture(StackCapture{Mode: CaptureSampled, Rate: 1})
+	spawnStack := captureSpawnStack(0)
+	SetStackCapture(StackCapture{Mode: CaptureSampled, Rate: 4})
+
+	if actual := appendSpawnStack(nil, spawnStack); len(actual) != 0 {
+		t.Errorf("appendSpawnStack(nil, ...): got %#v, expected nothing for a stack not sampled", actual)
+	}
+
+	eg = NewCollectingErrorGroup(context.Background(), 1, 0)
+	for i := 0; i < 8; i++ {
+		if i%2 == 0 {
+			eg.Go(1, errorGroupify(dumbSleeper(0), io.EOF))
+		} else {
+			eg.Go(1, func(context.Context) ErrorWithStack { panic(42) })
+		}
+	}
+
+	captured = 0
+	if errs, ok := eg.Wait().(MultiError); !ok || len(errs) != 8 {
+		t.Errorf("ErrorGroup#Wait(): got %#v, expected MultiError with 8 errors", errs)
+	} else {
+		for _, err := range errs {
+			if stack := err.StackTrace(); len(stack) > 0 {
+				captured++
+
+				if len(err.(AdvancedError).StackSegments()) > 1 {
+					t.Errorf("ErrorGroup#Wait(): got %+v, expected at most 1 goroutine separator", err)
+				}
+			}
+		}
+	}
+
+	if captured != 2 {
+		t.Errorf("ErrorGroup#Wait(): captured %d of 8 stacks, expected 2", captured)
+	}
+
+	if actual := GetStackCapture(); actual.Mode != CaptureSampled || actual.Rate != 4 {
+		t.Errorf("GetStackCapture(): got %#v, expected the policy set", actual)
+	}
+}
+
+func TestSymbolizeStack(t *testing.T) {
+	var stack errors.StackTrace
+	recurse(8, func() { stack = GetStack(0) })
+
+	expected := fmt.Sprint(symbolizeStackUncached(stack))
+
+	for i := 0; i < 2; i++ {
+		if actual := fmt.Sprint(symbolizeStack(stack)); actual != expected {
+			t.Errorf("symbolizeStack(%#v): got %s, expected %s", stack, actual, expected)
+		}
+	}
+
+	if actual := symbolizeStack(nil); actual != nil {
+		t.Errorf("symbolizeStack(nil): got %#v, expected nil", actual)
+	}
+}
+
+func BenchmarkSymbolizeStack(b *testing.B) {
+	benchmarkSymbolizeStack(b, symbolizeStack)
+}
+
+func BenchmarkSymbolizeStack_Uncached(b *testing.B) {
+	benchmarkSymbolizeStack(b, symbolizeStackUncached)
+}
+
+func benchmarkSymbolizeStack(b *testing.B, symbolize func(errors.StackTrace) []StackFrame) {
+	var stack errors.StackTrace
+	recurse(32, func() { stack = GetStack(0) })
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		symbolize(stack)
+	}
+}
+
+func BenchmarkAdvancedError_MarshalJSON(b *testing.B) {
+	benchmarkAdvancedError(b, false, func(ae AdvancedError) { ae.MarshalJSON() })
+}
+
+func BenchmarkAdvancedError_String(b *testing.B) {
+	benchmarkAdvancedError(b, false, func(ae AdvancedError) { _ = ae.String() })
+}
+
+func BenchmarkAdvancedError_String_Uncached(b *testing.B) {
+	benchmarkAdvancedError(b, true, func(ae AdvancedError) { _ = ae.String() })
+}
+
+func BenchmarkAdvancedError_Format(b *testing.B) {
+	benchmarkAdvancedError(b, false, func(ae AdvancedError) { fmt.Fprintf(ioutil.Discard, "%+v", ae) })
+}
+
+func BenchmarkAdvancedError_Format_Uncached(b *testing.B) {
+	benchmarkAdvancedError(b, true, func(ae AdvancedError) { fmt.Fprintf(ioutil.Discard, "%+v", ae) })
+}
+
+func BenchmarkAdvancedError_MarshalText(b *testing.B) {
+	benchmarkAdvancedError(b, false, func(ae AdvancedError) { ae.MarshalText() })
+}
+
+func BenchmarkAdvancedError_MarshalText_Uncached(b *testing.B) {
+	benchmarkAdvancedError(b, true, func(ae AdvancedError) { ae.MarshalText() })
+}
+
+// benchmarkAdvancedError benchmarks $render on an AdvancedError with a deep stack,
+// with an empty frameCache for each call if $uncached.
+func benchmarkAdvancedError(b *testing.B, uncached bool, render func(AdvancedError)) {
+	var ae AdvancedError
+	recurse(32, func() { ae = AttachStackToError(io.EOF, 0).(AdvancedError) })
+
+	render(ae)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if uncached {
+			b.StopTimer()
+			resetFrameCache()
+			b.StartTimer()
+		}
+
+		render(ae)
+	}
+}
+
+// resetFrameCache empties frameCache.
+func resetFrameCache() {
+	frameCache.Range(func(key, _ interface{}) bool {
+		frameCache.Delete(key)
+		return true
+	})
+}
+
+// symbolizeStackUncached is symbolizeStack without cache, as a baseline for benchmarks.
+func symbolizeStackUncached(stack errors.StackTrace) []StackFrame {
+	var frames []StackFrame
+	cf := runtime.CallersFrames(stackAsRaw(stack))
+
+	for {
+		fr, more := cf.Next()
+		frames = append(frames, StackFrame{File: fr.File, Line: fr.Line, Function: fr.Function})
+
+		if !more {
+			break
+		}
+	}
+
+	return frames
+}
+
+type formatterFunc func(fmt.State, rune)
+
+var _ fmt.Formatter = formatterFunc(nil)
+
+func (ff formatterFunc) Format(fs fmt.State, verb rune) {
+	ff(fs, verb)
+}
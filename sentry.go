@@ -0,0 +1,258 @@
+package fuel
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// SentryOptions customize NewSentryEvent().
+type SentryOptions struct {
+	// InAppPrefixes mark frames of functions starting with one of them (e.g. a module path) as in_app.
+	InAppPrefixes []string
+	Tags          map[string]string
+	// Level defaults to "error".
+	Level string
+	// Fingerprint is used to compute the event's fingerprint, see Fingerprint().
+	Fingerprint FingerprintOptions
+}
+
+// SentryEvent is an event document as understood by Sentry-compatible endpoints.
+type SentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Exception   SentryExceptions       `json:"exception"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Fingerprint []string               `json:"fingerprint,omitempty"`
+}
+
+type SentryExceptions struct {
+	// Values are ordered from the innermost error to the outermost one.
+	Values []SentryException `json:"values"`
+}
+
+type SentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *SentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type SentryStacktrace struct {
+	// Frames are ordered from the oldest call to the newest one.
+	Frames []SentryFrame `json:"frames"`
+}
+
+type SentryFrame struct {
+	Function string `json:"function,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+	*SourceContext
+}
+
+// NewSentryEvent converts the error chain $err to a SentryEvent.
+// Every error along the chain (see RootCause) becomes an exception, except AdvancedError and RemoteError
+// which just lend their stack to the innermost exception of the errors they wrap
+// (up to the next error with an own stack).
+// The fields (see GetFields) become extra data.
+func NewSentryEvent(err error, opts SentryOptions) SentryEvent {
+	event := SentryEvent{
+		EventID:   newSentryEventID(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     opts.Level,
+		Platform:  "go",
+		Tags:      opts.Tags,
+		Extra:     fieldsMapOf(err),
+	}
+
+	if event.Level == "" {
+		event.Level = "error"
+	}
+
+	if err == nil {
+		return event
+	}
+
+	event.Fingerprint = []string{Fingerprint(err, opts.Fingerprint)}
+
+	var lentStack StackTracer
+	seen := map[errorIdentity]struct{}{}
+
+	for err != nil {
+		// Like WalkError, stop at cycles.
+		if id, ok := identifyError(err); ok {
+			if _, ok := seen[id]; ok {
+				break
+			}
+
+			seen[id] = struct{}{}
+		}
+
+		var next error
+		if children := unwrapError(err); len(children) > 0 {
+			next = children[0]
+		}
+
+		st, hasST := err.(StackTracer)
+		if hasST && !hasStack(st) {
+			hasST = false
+		}
+
+		switch err.(type) {
+		case AdvancedError, RemoteError, FilteredError, FieldsError:
+			if next != nil {
+				if hasST && lentStack == nil {
+					lentStack = st
+				}
+
+				err = next
+				continue
+			}
+		}
+
+		exception := SentryException{Type: fmt.Sprintf("%T", err), Value: GetRedactor().Redact(err.Error())}
+
+		if hasST {
+			lentStack = nil
+		} else if nextST, ok := next.(StackTracer); next == nil || ok && hasStack(nextST) {
+			st = lentStack
+			lentStack = nil
+		}
+
+		if st != nil {
+			exception.Stacktrace = newSentryStacktrace(st, opts.InAppPrefixes)
+		}
+
+		event.Exception.Values = append([]SentryException{exception}, event.Exception.Values...)
+		err = next
+	}
+
+	return event
+}
+
+func newSentryStacktrace(st StackTracer, inAppPrefixes []string) *SentryStacktrace {
+	frames, _ := joinSegments(renderedSegmentsOf(st))
+	stacktrace := &SentryStacktrace{Frames: make([]SentryFrame, 0, len(frames))}
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		pkg := funcPackage(frame.Function)
+		function := strings.TrimPrefix(strings.TrimPrefix(frame.Function, pkg), ".")
+
+		// The linker escapes e.g. dots in the last element of import paths.
+		if unescaped, err := url.PathUnescape(pkg); err == nil {
+			pkg = unescaped
+		}
+
+		sf := SentryFrame{
+			Function:      function,
+			Module:        pkg,
+			Filename:      path.Base(frame.File),
+			AbsPath:       frame.File,
+			Lineno:        frame.Line,
+			SourceContext: frame.SourceContext,
+		}
+
+		for _, prefix := range inAppPrefixes {
+			if strings.HasPrefix(pkg+"."+function, prefix) {
+				sf.InApp = true
+				break
+			}
+		}
+
+		stacktrace.Frames = append(stacktrace.Frames, sf)
+	}
+
+	return stacktrace
+}
+
+func newSentryEventID() string {
+	id := make([]byte, 16)
+
+	if _, err := rand.Read(id); err != nil {
+		// The ID just has to be unique, not unpredictable.
+		binary.BigEndian.PutUint64(id, uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(id[8:], mrand.Uint64())
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// SentryTransport sends SentryEvents to a Sentry-compatible endpoint.
+type SentryTransport struct {
+	// DSN is of the form https://PUBLIC_KEY@HOST/PROJECT_ID.
+	DSN string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Send sends $event to the store endpoint specified by st.DSN.
+func (st SentryTransport) Send(ctx context.Context, event SentryEvent) ErrorWithStack {
+	dsn, err := url.Parse(st.DSN)
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return AttachStackToError(fmt.Errorf("sentry: DSN %#v lacks a public key", st.DSN), 0)
+	}
+
+	dir, project := path.Split(dsn.Path)
+	if project == "" {
+		return AttachStackToError(fmt.Errorf("sentry: DSN %#v lacks a project ID", st.DSN), 0)
+	}
+
+	endpoint := url.URL{Scheme: dsn.Scheme, Host: dsn.Host, Path: dir + "api/" + project + "/store/"}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(
+		"X-Sentry-Auth",
+		"Sentry sentry_version=7, sentry_client=FUeL.go, sentry_key="+dsn.User.Username(),
+	)
+
+	client := st.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return AttachStackToError(err, 0)
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return AttachStackToError(fmt.Errorf("sentry: got HTTP status %s", resp.Status), 0)
+	}
+
+	return nil
+}
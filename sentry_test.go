@@ -0,0 +1,127 @@
+package fuel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewSentryEvent(t *testing.T) {
+	err := Wrap(AttachFieldsToError(io.EOF, 0, Field{"user", "alice"}), "reading config")
+	opts := SentryOptions{InAppPrefixes: []string{"github.com/Al2Klimov/FUeL.go."}, Tags: map[string]string{"a": "b"}}
+	event := NewSentryEvent(err, opts)
+
+	if len(event.EventID) != 32 {
+		t.Errorf("NewSentryEvent(%#v, ...).EventID: got %#v, expected 32 hex digits", err, event.EventID)
+	}
+
+	if event.Level != "error" || event.Platform != "go" {
+		t.Errorf("NewSentryEvent(%#v, ...): got level %#v, platform %#v", err, event.Level, event.Platform)
+	}
+
+	if event.Tags["a"] != "b" {
+		t.Errorf("NewSentryEvent(%#v, ...).Tags: got %#v, expected a=b", err, event.Tags)
+	}
+
+	if event.Extra["user"] != "alice" {
+		t.Errorf("NewSentryEvent(%#v, ...).Extra: got %#v, expected user=alice", err, event.Extra)
+	}
+
+	if fp := Fingerprint(err, FingerprintOptions{}); len(event.Fingerprint) != 1 || event.Fingerprint[0] != fp {
+		t.Errorf("NewSentryEvent(%#v, ...).Fingerprint: got %#v, expected [%#v]", err, event.Fingerprint, fp)
+	}
+
+	values := event.Exception.Values
+	if len(values) != 2 {
+		t.Fatalf("NewSentryEvent(%#v, ...).Exception.Values: got %#v, expected 2 exceptions", err, values)
+	}
+
+	if values[0].Value != "EOF" || values[0].Type != "*errors.errorString" {
+		t.Errorf("NewSentryEvent(%#v, ...).Exception.Values[0]: got %#v, expected EOF", err, values[0])
+	}
+
+	if values[1].Value != "reading config: EOF" || values[1].Type != "fuel.MessageError" {
+		t.Errorf("NewSentryEvent(%#v, ...).Exception.Values[1]: got %#v, expected the message", err, values[1])
+	}
+
+	if st := values[1].Stacktrace; st != nil {
+		t.Errorf("NewSentryEvent(%#v, ...).Exception.Values[1].Stacktrace: got %#v, expected nil", err, st)
+	}
+
+	if st := values[0].Stacktrace; st == nil || len(st.Frames) < 2 {
+		t.Errorf("NewSentryEvent(%#v, ...).Exception.Values[0].Stacktrace: got %#v, expected frames", err, st)
+	} else {
+		last := st.Frames[len(st.Frames)-1]
+		if last.Function != "TestNewSentryEvent" || last.Module != "github.com/Al2Klimov/FUeL.go" ||
+			last.Filename != "sentry_test.go" || !last.InApp {
+			t.Errorf("NewSentryEvent(%#v, ...): got innermost frame %#v, expected this test", err, last)
+		}
+
+		if first := st.Frames[0]; first.InApp {
+			t.Errorf("NewSentryEvent(%#v, ...): got outermost frame %#v, expected not in_app", err, first)
+		}
+	}
+
+	if event := NewSentryEvent(nil, SentryOptions{Level: "warning"}); event.Level != "warning" ||
+		len(event.Exception.Values) != 0 || event.Fingerprint != nil {
+		t.Errorf("NewSentryEvent(nil, ...): got %#v, expected no exceptions", event)
+	}
+}
+
+func TestNewSentryEvent_Cycle(t *testing.T) {
+	cycle := &cyclicError{}
+	cycle.next = &cyclicError{cycle}
+
+	if actual := NewSentryEvent(cycle, SentryOptions{}).Exception.Values; len(actual) != 2 {
+		t.Errorf("NewSentryEvent(%#v, ...): got %#v, expected 2 exceptions", cycle, actual)
+	}
+}
+
+func TestSentryTransport_Send(t *testing.T) {
+	var method, path, auth string
+	var received SentryEvent
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	event := NewSentryEvent(AttachStackToError(io.EOF, 0), SentryOptions{})
+	transport := SentryTransport{DSN: strings.Replace(server.URL, "://", "://pubkey@", 1) + "/sub/42"}
+
+	if err := transport.Send(context.Background(), event); err != nil {
+		t.Errorf("%#v.Send(...): got %#v, expected nil", transport, err)
+	}
+
+	if method != http.MethodPost || path != "/sub/api/42/store/" {
+		t.Errorf("%#v.Send(...): got %s %s, expected POST /sub/api/42/store/", transport, method, path)
+	}
+
+	if !strings.Contains(auth, "sentry_key=pubkey") || !strings.Contains(auth, "sentry_version=7") {
+		t.Errorf("%#v.Send(...): got X-Sentry-Auth %#v, expected the key", transport, auth)
+	}
+
+	if received.EventID != event.EventID || len(received.Exception.Values) != 1 {
+		t.Errorf("%#v.Send(%#v): server got %#v", transport, event, received)
+	}
+
+	status = http.StatusTooManyRequests
+	if err := transport.Send(context.Background(), event); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("%#v.Send(...): got %#v, expected HTTP 429", transport, err)
+	}
+
+	for _, dsn := range []string{server.URL + "/42", strings.Replace(server.URL, "://", "://pubkey@", 1) + "/"} {
+		if err := (SentryTransport{DSN: dsn}).Send(context.Background(), event); err == nil {
+			t.Errorf("SentryTransport{DSN: %#v}.Send(...): got nil, expected an error", dsn)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build go1.21
+// +build go1.21
+
+package fuel
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+var _ slog.LogValuer = AdvancedError{}
+
+// LogValue returns ErrorLogValue(ae).
+func (ae AdvancedError) LogValue() slog.Value {
+	return ErrorLogValue(ae)
+}
+
+var _ slog.LogValuer = RemoteError{}
+
+// LogValue returns ErrorLogValue(re).
+func (re RemoteError) LogValue() slog.Value {
+	return ErrorLogValue(re)
+}
+
+var _ slog.LogValuer = Secret{}
+
+// LogValue returns the Replacement of GetRedactor().
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// ErrorLogValue represents any error (chain) as a slog group with the message, the type (of the root cause),
+// the stack frames (if any) and the fields (see GetFields, if any).
+func ErrorLogValue(err error) slog.Value {
+	if err == nil {
+		return slog.Value{}
+	}
+
+	attrs := []slog.Attr{slog.String("message", GetRedactor().Redact(err.Error())), slog.String("type", errorType(err))}
+
+	if st := outermostStackTracer(err); st != nil {
+		frames, _ := joinSegments(renderedSegmentsOf(st))
+		attrs = append(attrs, slog.Any("stack", frames))
+	}
+
+	if fields := fieldsMapOf(err); len(fields) > 0 {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		group := make([]slog.Attr, 0, len(keys))
+		for _, k := range keys {
+			group = append(group, slog.Any(k, fields[k]))
+		}
+
+		attrs = append(attrs, slog.Attr{Key: "fields", Value: slog.GroupValue(group...)})
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// NewErrorHandler wraps $h so that all attributes with error values are expanded via ErrorLogValue.
+func NewErrorHandler(h slog.Handler) slog.Handler {
+	return errorHandler{h}
+}
+
+type errorHandler struct {
+	h slog.Handler
+}
+
+var _ slog.Handler = errorHandler{}
+
+func (eh errorHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return eh.h.Enabled(ctx, level)
+}
+
+func (eh errorHandler) Handle(ctx context.Context, record slog.Record) error {
+	expanded := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		expanded.AddAttrs(expandErrorAttr(attr))
+		return true
+	})
+
+	return eh.h.Handle(ctx, expanded)
+}
+
+func (eh errorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	expanded := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		expanded = append(expanded, expandErrorAttr(attr))
+	}
+
+	return errorHandler{eh.h.WithAttrs(expanded)}
+}
+
+func (eh errorHandler) WithGroup(name string) slog.Handler {
+	return errorHandler{eh.h.WithGroup(name)}
+}
+
+// expandErrorAttr replaces error values in $attr (and its group members) via ErrorLogValue.
+func expandErrorAttr(attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			attr.Value = ErrorLogValue(err)
+		}
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		expanded := make([]slog.Attr, 0, len(group))
+
+		for _, member := range group {
+			expanded = append(expanded, expandErrorAttr(member))
+		}
+
+		attr.Value = slog.GroupValue(expanded...)
+	case slog.KindLogValuer:
+		attr.Value = attr.Value.Resolve()
+		if attr.Value.Kind() != slog.KindLogValuer {
+			return expandErrorAttr(attr)
+		}
+	}
+
+	return attr
+}
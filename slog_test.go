@@ -0,0 +1,99 @@
+//go:build go1.21
+// +build go1.21
+
+package fuel
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestAdvancedError_LogValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := AttachFieldsToError(io.EOF, 0, Field{"id", 42})
+
+	slog.New(slog.NewJSONHandler(buf, nil)).Error("failed", "err", err)
+
+	var record struct {
+		Err struct {
+			Message string       `json:"message"`
+			Type    string       `json:"type"`
+			Stack   []StackFrame `json:"stack"`
+			Fields  struct {
+				ID int `json:"id"`
+			} `json:"fields"`
+		} `json:"err"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("slog: got bad JSON %#v: %s", buf.String(), err.Error())
+	}
+
+	if record.Err.Message != "EOF" || record.Err.Type != "*errors.errorString" || len(record.Err.Stack) < 1 ||
+		record.Err.Stack[0].Function != "github.com/Al2Klimov/FUeL%2ego.TestAdvancedError_LogValue" ||
+		record.Err.Fields.ID != 42 {
+		t.Errorf("slog: got %#v", buf.String())
+	}
+
+	if actual := ErrorLogValue(nil); actual.Kind() != slog.KindAny || actual.Any() != nil {
+		t.Errorf("ErrorLogValue(nil): got %#v, expected the zero slog.Value", actual)
+	}
+}
+
+func TestNewErrorHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewErrorHandler(slog.NewJSONHandler(buf, nil)))
+	thirdParty := errors.New("x")
+
+	logger.With("pre", thirdParty).Error("failed", "err", thirdParty, slog.Group("g", "err", io.EOF), "n", 1)
+
+	var record struct {
+		Pre struct {
+			Stack []StackFrame `json:"stack"`
+		} `json:"pre"`
+		Err struct {
+			Message string       `json:"message"`
+			Stack   []StackFrame `json:"stack"`
+		} `json:"err"`
+		G struct {
+			Err struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"err"`
+		} `json:"g"`
+		N int `json:"n"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("slog: got bad JSON %#v: %s", buf.String(), err.Error())
+	}
+
+	if len(record.Pre.Stack) < 1 || record.Err.Message != "x" || len(record.Err.Stack) < 1 ||
+		record.G.Err.Message != "EOF" || record.G.Err.Type != "*errors.errorString" || record.N != 1 {
+		t.Errorf("slog: got %#v", buf.String())
+	}
+}
+
+func TestSecret_LogValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := AttachFieldsToError(errors.New("login as jane@example.com failed"), 0, SecretField("password", "hunter2"))
+
+	SetRedactor(&Redactor{Patterns: CommonSecretPatterns()})
+	defer SetRedactor(nil)
+
+	slog.New(slog.NewTextHandler(buf, nil)).Error("failed", "err", err, "token", Secret{"t0k3n"})
+
+	for _, secret := range []string{"jane@example.com", "hunter2", "t0k3n"} {
+		if bytes.Contains(buf.Bytes(), []byte(secret)) {
+			t.Errorf("slog: got %#v, expected no %#v", buf.String(), secret)
+		}
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte("err.fields.password=[REDACTED]")) {
+		t.Errorf("slog: got %#v, expected the password redacted", buf.String())
+	}
+}
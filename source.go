@@ -0,0 +1,185 @@
+package fuel
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SourceContext holds the source code lines around the line of a StackFrame.
+type SourceContext struct {
+	PreContext  []string `json:"pre_context,omitempty"`
+	ContextLine string   `json:"context_line"`
+	PostContext []string `json:"post_context,omitempty"`
+}
+
+var sourceContext atomic.Value
+
+func init() {
+	sourceContext.Store(0)
+}
+
+// SetSourceContext makes stacks rendered afterwards include $lines source code lines
+// before and after the line of each frame whose source file is readable. 0 disables that.
+// Only frames of stacks captured by the current process are affected, not e.g. the ones of RemoteErrors.
+func SetSourceContext(lines int) {
+	if lines < 0 {
+		lines = 0
+	}
+
+	sourceContext.Store(lines)
+}
+
+// GetSourceContext returns the amount of lines set by SetSourceContext.
+func GetSourceContext() int {
+	return sourceContext.Load().(int)
+}
+
+// maxSourceCache limits the amount of files in sourceCache.
+const maxSourceCache = 1024
+
+var (
+	// sourceCache maps file names to their elements in sourceLRU. It's process-wide.
+	sourceCache = map[string]*list.Element{}
+	// sourceLRU holds the cached sourceFiles, the most recently used one first.
+	sourceLRU      = list.New()
+	sourceCacheMtx sync.Mutex
+)
+
+// sourceFile holds the lines of a file, nil if unreadable.
+type sourceFile struct {
+	name  string
+	lines []string
+}
+
+// sourceLines returns the lines of $file, nil if unreadable.
+// Of more than maxSourceCache files the least recently used ones are read again on demand.
+func sourceLines(file string) []string {
+	if lines, ok := cachedSourceLines(file); ok {
+		return lines
+	}
+
+	var lines []string
+	if content, err := ioutil.ReadFile(file); err == nil {
+		lines = strings.Split(string(bytes.TrimSuffix(content, []byte("\n"))), "\n")
+
+		for i, line := range lines {
+			lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+
+	sourceCacheMtx.Lock()
+	defer sourceCacheMtx.Unlock()
+
+	if elem, ok := sourceCache[file]; ok {
+		sourceLRU.MoveToFront(elem)
+		return elem.Value.(sourceFile).lines
+	}
+
+	sourceCache[file] = sourceLRU.PushFront(sourceFile{file, lines})
+
+	if sourceLRU.Len() > maxSourceCache {
+		delete(sourceCache, sourceLRU.Remove(sourceLRU.Back()).(sourceFile).name)
+	}
+
+	return lines
+}
+
+// cachedSourceLines returns the lines of $file from sourceCache, if any, and marks them as recently used.
+func cachedSourceLines(file string) ([]string, bool) {
+	sourceCacheMtx.Lock()
+	defer sourceCacheMtx.Unlock()
+
+	if elem, ok := sourceCache[file]; ok {
+		sourceLRU.MoveToFront(elem)
+		return elem.Value.(sourceFile).lines, true
+	}
+
+	return nil, false
+}
+
+// withSourceContext adds SourceContexts as specified by GetSourceContext() to the frames of $segs lacking one.
+// The frames must have been symbolized by the current process, so that they refer to its own source files.
+func withSourceContext(segs []frameSegment) []frameSegment {
+	n := GetSourceContext()
+	if n < 1 {
+		return segs
+	}
+
+	withContext := make([]frameSegment, 0, len(segs))
+
+	for _, seg := range segs {
+		frames := make([]StackFrame, 0, len(seg.frames))
+
+		for _, frame := range seg.frames {
+			if frame.SourceContext == nil {
+				frame.SourceContext = sourceContextOf(frame.File, frame.Line, n)
+			}
+
+			frames = append(frames, frame)
+		}
+
+		withContext = append(withContext, frameSegment{seg.createdBy, frames})
+	}
+
+	return withContext
+}
+
+// sourceContextOf returns $n lines around line $line of $file, nil if not available.
+func sourceContextOf(file string, line, n int) *SourceContext {
+	if file == "" || line < 1 {
+		return nil
+	}
+
+	lines := sourceLines(file)
+	if line > len(lines) {
+		return nil
+	}
+
+	from := line - 1 - n
+	if from < 0 {
+		from = 0
+	}
+
+	to := line + n
+	if to > len(lines) {
+		to = len(lines)
+	}
+
+	return &SourceContext{
+		PreContext:  append([]string(nil), lines[from:line-1]...),
+		ContextLine: lines[line-1],
+		PostContext: append([]string(nil), lines[line:to]...),
+	}
+}
+
+// formatSourceContext writes the lines of $sc, numbered relative to $line, each prefixed with "\n\t".
+func formatSourceContext(w io.Writer, sc *SourceContext, line int) {
+	first := line - len(sc.PreContext)
+	width := len(strconv.Itoa(line + len(sc.PostContext)))
+
+	for i, text := range sc.PreContext {
+		formatSourceLine(w, " ", width, first+i, text)
+	}
+
+	formatSourceLine(w, ">", width, line, sc.ContextLine)
+
+	for i, text := range sc.PostContext {
+		formatSourceLine(w, " ", width, line+1+i, text)
+	}
+}
+
+func formatSourceLine(w io.Writer, marker string, width, number int, text string) {
+	fmt.Fprintf(w, "\n\t%s %*d |", marker, width, number)
+
+	if text != "" {
+		io.WriteString(w, " ")
+		io.WriteString(w, text)
+	}
+}
@@ -0,0 +1,129 @@
+package fuel
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSetSourceContext(t *testing.T) {
+	err := AttachStackToError(io.EOF, 0)
+	line := FramesOf(err)[0].Line
+
+	if actual := fmt.Sprintf("%+v", err); strings.Contains(actual, " | ") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected no source context", err, actual)
+	}
+
+	SetSourceContext(1)
+	defer SetSourceContext(0)
+
+	expected := fmt.Sprintf(
+		"/source_test.go:%d\n\t  %d | func TestSetSourceContext(t *testing.T) {\n"+
+			"\t> %d | \terr := AttachStackToError(io.EOF, 0)\n\t  %d | \tline := FramesOf(err)[0].Line\ntesting.",
+		line, line-1, line, line+1,
+	)
+
+	if actual := fmt.Sprintf("%+v", err); !strings.Contains(actual, expected) {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected it to contain %#v", err, actual, expected)
+	}
+
+	jsn, errJS := json.Marshal(err)
+	if errJS != nil {
+		t.Fatalf("json.Marshal(%#v): got error %#v", err, errJS)
+	}
+
+	if !strings.Contains(string(jsn), `"pre_context":["`) ||
+		!strings.Contains(string(jsn), `"context_line":"\terr := AttachStackToError(io.EOF, 0)"`) {
+		t.Errorf("json.Marshal(%#v): got %#v, expected source context", err, string(jsn))
+	}
+
+	var re RemoteError
+	if errJS := json.Unmarshal(jsn, &re); errJS != nil {
+		t.Fatalf("json.Unmarshal(%#v, ...): got error %#v", string(jsn), errJS)
+	}
+
+	SetSourceContext(0)
+
+	if actual := fmt.Sprintf("%+v", re); !strings.Contains(actual, expected) {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected it to contain %#v", re, actual, expected)
+	}
+
+	SetSourceContext(2)
+
+	missing := RemoteError{Message: "EOF", Frames: []StackFrame{{File: "/nonexistent/x.go", Line: 3, Function: "x"}}}
+	if actual := fmt.Sprintf("%+v", missing); actual != "EOF\nx\n\t/nonexistent/x.go:3" {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected no source context", missing, actual)
+	}
+
+	if missing.Frames[0].SourceContext != nil {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): modified the frames", missing)
+	}
+
+	_, file, line, _ := runtime.Caller(0)
+	foreign := RemoteError{Message: "EOF", Frames: []StackFrame{{File: file, Line: line, Function: "x"}}}
+
+	if actual := fmt.Sprintf("%+v", foreign); strings.Contains(actual, " | ") {
+		t.Errorf("fmt.Sprintf(\"%%+v\", %#v): got %#v, expected no source context", foreign, actual)
+	}
+
+	if jsn, _ := json.Marshal(foreign); strings.Contains(string(jsn), "context_line") {
+		t.Errorf("json.Marshal(%#v): got %#v, expected no source context", foreign, string(jsn))
+	}
+}
+
+func TestSourceLines(t *testing.T) {
+	defer resetSourceCache()
+	resetSourceCache()
+
+	for i := 0; i <= maxSourceCache; i++ {
+		sourceLines(fmt.Sprintf("/nonexistent/%d.go", i))
+
+		if i == 1 {
+			sourceLines("/nonexistent/0.go")
+		}
+	}
+
+	sourceCacheMtx.Lock()
+	defer sourceCacheMtx.Unlock()
+
+	if len(sourceCache) != maxSourceCache || sourceLRU.Len() != maxSourceCache {
+		t.Errorf("sourceLines(): cached %d files, expected %d", len(sourceCache), maxSourceCache)
+	}
+
+	if _, ok := sourceCache["/nonexistent/1.go"]; ok {
+		t.Error("sourceLines(): kept the least recently used file, expected it to be evicted")
+	}
+
+	if _, ok := sourceCache["/nonexistent/0.go"]; !ok {
+		t.Error("sourceLines(): evicted a recently used file, expected the least recently used one")
+	}
+}
+
+// resetSourceCache empties sourceCache.
+func resetSourceCache() {
+	sourceCacheMtx.Lock()
+	defer sourceCacheMtx.Unlock()
+
+	sourceCache = map[string]*list.Element{}
+	sourceLRU.Init()
+}
+
+func TestSourceContextOf(t *testing.T) {
+	if actual := sourceContextOf("source_test.go", 1, 2); actual == nil {
+		t.Errorf("sourceContextOf(\"source_test.go\", 1, 2): got nil, expected a SourceContext")
+	} else if len(actual.PreContext) != 0 || actual.ContextLine != "package fuel" || len(actual.PostContext) != 2 {
+		t.Errorf("sourceContextOf(\"source_test.go\", 1, 2): got %#v, expected the first three lines", actual)
+	}
+
+	if actual := sourceContextOf("source_test.go", 1000000, 2); actual != nil {
+		t.Errorf("sourceContextOf(\"source_test.go\", 1000000, 2): got %#v, expected nil", actual)
+	}
+
+	if actual := sourceContextOf("", 1, 2); actual != nil {
+		t.Errorf("sourceContextOf(\"\", 1, 2): got %#v, expected nil", actual)
+	}
+}
@@ -2,6 +2,7 @@ package fuel
 
 import (
 	"context"
+	"github.com/pkg/errors"
 	"golang.org/x/sync/semaphore"
 	"sync"
 )
@@ -14,9 +15,15 @@ type RunQueue interface {
 }
 
 // ElasticQueue runs enqueued tasks immediately until context cancellation.
+// Panics of tasks are recovered as PanicError, see Panics().
 type ElasticQueue struct {
-	ctx context.Context
-	wg  sync.WaitGroup
+	// RePanic makes Wait() panic with the first recovered panic.
+	RePanic bool
+
+	ctx       context.Context
+	wg        sync.WaitGroup
+	panics    []ErrorWithStack
+	panicsMtx sync.Mutex
 }
 
 // NewElasticQueue creates a new ElasticQueue. $ctx is forwarded to Enqueue()d tasks.
@@ -33,21 +40,66 @@ func (eq *ElasticQueue) Enqueue(_ int64, f func(context.Context)) {
 	default:
 	}
 
+	eq.enqueue(captureSpawnStack(0), f)
+}
+
+func (eq *ElasticQueue) Wait() {
+	eq.wg.Wait()
+
+	if eq.RePanic {
+		eq.rePanic()
+	}
+}
+
+// Panics returns the panics recovered since the last call, each as PanicError with the stacks
+// of the panicking task and of the Enqueue() caller.
+func (eq *ElasticQueue) Panics() []ErrorWithStack {
+	eq.panicsMtx.Lock()
+	defer eq.panicsMtx.Unlock()
+
+	panics := eq.panics
+	eq.panics = nil
+
+	return panics
+}
+
+// enqueue runs $f which has been enqueued from $stack.
+func (eq *ElasticQueue) enqueue(stack errors.StackTrace, f func(context.Context)) {
 	eq.wg.Add(1)
 
 	go func() {
 		defer eq.wg.Done()
 
+		returned := false
+		defer func() {
+			// Not just recover() != nil, that would miss panic(nil) and runtime.Goexit().
+			if !returned {
+				err := panicToError(recover(), stack)
+
+				eq.panicsMtx.Lock()
+				eq.panics = append(eq.panics, err)
+				eq.panicsMtx.Unlock()
+			}
+		}()
+
 		f(eq.ctx)
+		returned = true
 	}()
 }
 
-func (eq *ElasticQueue) Wait() {
-	eq.wg.Wait()
+// rePanic panics with the first recovered panic, if any.
+func (eq *ElasticQueue) rePanic() {
+	if panics := eq.Panics(); len(panics) > 0 {
+		panic(panics[0])
+	}
 }
 
 // LimitedQueue runs enqueued tasks with limited concurrency in FIFO order until context cancellation.
+// Panics of tasks are recovered as PanicError, see Panics().
 type LimitedQueue struct {
+	// RePanic makes Wait() panic with the first recovered panic.
+	RePanic bool
+
 	eq    ElasticQueue
 	items []queueItem
 	mtx   sync.Mutex
@@ -72,23 +124,41 @@ func (lq *LimitedQueue) Enqueue(weight int64, f func(context.Context)) {
 	default:
 	}
 
+	stack := captureSpawnStack(0)
 	lq.mtx.Lock()
 
 	if len(lq.items) < 1 && lq.sema.TryAcquire(weight) {
 		lq.mtx.Unlock()
-		lq.forward(weight, f)
+		lq.forward(weight, stack, f)
 	} else {
-		lq.items = append(lq.items, queueItem{weight, f})
+		lq.items = append(lq.items, queueItem{weight, stack, f})
 		lq.mtx.Unlock()
 	}
 }
 
 func (lq *LimitedQueue) Wait() {
 	lq.eq.Wait()
+
+	if lq.RePanic {
+		lq.eq.rePanic()
+	}
+}
+
+// Panics returns the panics recovered since the last call, each as PanicError with the stacks
+// of the panicking task and of the Enqueue() caller.
+func (lq *LimitedQueue) Panics() []ErrorWithStack {
+	return lq.eq.Panics()
 }
 
-func (lq *LimitedQueue) forward(weight int64, f func(context.Context)) {
-	lq.eq.Enqueue(weight, func(ctx context.Context) {
+func (lq *LimitedQueue) forward(weight int64, stack errors.StackTrace, f func(context.Context)) {
+	select {
+	case <-lq.eq.ctx.Done():
+		lq.sema.Release(weight)
+		return
+	default:
+	}
+
+	lq.eq.enqueue(stack, func(ctx context.Context) {
 		defer lq.nextOnes()
 		defer lq.sema.Release(weight)
 
@@ -107,7 +177,7 @@ func (lq *LimitedQueue) nextOnes() {
 
 	for len(lq.items) > 0 {
 		if next := lq.items[0]; lq.sema.TryAcquire(next.weight) {
-			lq.forward(next.weight, next.f)
+			lq.forward(next.weight, next.stack, next.f)
 			lq.items = lq.items[1:]
 		} else {
 			break
@@ -119,5 +189,6 @@ func (lq *LimitedQueue) nextOnes() {
 
 type queueItem struct {
 	weight int64
+	stack  errors.StackTrace
 	f      func(context.Context)
 }
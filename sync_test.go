@@ -2,6 +2,8 @@ package fuel
 
 import (
 	"context"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -70,6 +72,63 @@ func TestLimitedQueue(t *testing.T) {
 	})
 }
 
+func TestQueue_Panic(t *testing.T) {
+	for _, queue := range []interface {
+		RunQueue
+		Panics() []ErrorWithStack
+	}{NewElasticQueue(context.Background()), NewLimitedQueue(context.Background(), 1)} {
+		var ran bool
+
+		queue.Enqueue(1, func(context.Context) { panic(42) })
+		queue.Enqueue(1, func(context.Context) { ran = true })
+		queue.Wait()
+
+		if !ran {
+			t.Errorf("%T: task after a panicked one didn't run", queue)
+		}
+
+		if panics := queue.Panics(); len(panics) != 1 {
+			t.Errorf("%T#Panics(): got %#v, expected one panic", queue, panics)
+		} else if ae, ok := panics[0].(AdvancedError); !ok || ae.Err != (PanicError{42}) ||
+			len(ae.StackSegments()) != 1 || !strings.HasSuffix(ae.StackSegments()[0].CreatedBy, "Queue.Enqueue") {
+			t.Errorf("%T#Panics(): got %#v, expected AdvancedError{Err: PanicError{42}} with 2 segments", queue, ae)
+		}
+
+		if panics := queue.Panics(); len(panics) != 0 {
+			t.Errorf("%T#Panics(): got %#v, expected no more panics", queue, panics)
+		}
+
+		queue.Enqueue(1, func(context.Context) { panic(nil) })
+		queue.Enqueue(1, func(context.Context) { runtime.Goexit() })
+		queue.Wait()
+
+		if panics := queue.Panics(); len(panics) != 2 {
+			t.Errorf("%T#Panics(): got %#v, expected panic(nil) and runtime.Goexit()", queue, panics)
+		}
+	}
+
+	eq := NewElasticQueue(context.Background())
+	lq := NewLimitedQueue(context.Background(), 1)
+	eq.RePanic = true
+	lq.RePanic = true
+
+	for _, queue := range []RunQueue{eq, lq} {
+		queue.Enqueue(1, func(context.Context) { panic(42) })
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%T#Wait(): didn't panic", queue)
+				} else if ae, ok := r.(AdvancedError); !ok || ae.Err != (PanicError{42}) {
+					t.Errorf("%T#Wait(): panicked with %#v, expected AdvancedError{Err: PanicError{42}}", queue, r)
+				}
+			}()
+
+			queue.Wait()
+		}()
+	}
+}
+
 func assertTakesTime(t *testing.T, dur, latency time.Duration, f func()) {
 	t.Helper()
 